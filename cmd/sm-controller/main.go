@@ -17,6 +17,8 @@ import (
 	"time"
 
 	"github.com/adriansr/sm-controller/internal/builder"
+	"github.com/adriansr/sm-controller/internal/crd"
+	"github.com/adriansr/sm-controller/internal/gateway"
 	"github.com/adriansr/sm-controller/internal/informer"
 	"github.com/adriansr/sm-controller/internal/schema"
 	"github.com/adriansr/sm-controller/internal/state"
@@ -27,6 +29,11 @@ import (
 	"golang.org/x/sync/errgroup"
 	coreV1 "k8s.io/api/core/v1"
 	networkingV1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 
@@ -43,6 +50,17 @@ type options struct {
 	kubeConfigPath string
 	apiServer      string
 	apiToken       string
+
+	configMap           string
+	clusterName         string
+	jobNameTemplate     string
+	targetTemplate      string
+	checkLabels         string
+	copyLabels          string
+	copyNamespaceLabels string
+	alertSensitivity    string
+	basicMetricsOnly    bool
+	gatewayAPI          bool
 }
 
 func (o *options) newFlagSetWithDefaults(name string) *flag.FlagSet {
@@ -57,6 +75,16 @@ func (o *options) newFlagSetWithDefaults(name string) *flag.FlagSet {
 	fs.StringVar(&o.kubeConfigPath, "kubeconfig", "", "path to kube config file")
 	fs.StringVar(&o.apiServer, "server", "", "Synthetic-monitoring API server URL")
 	fs.StringVar(&o.apiToken, "token", "", "Synthetic-monitoring API token")
+	fs.StringVar(&o.configMap, "config-map", "", "controller config ConfigMap as namespace/name, overrides the check flags")
+	fs.StringVar(&o.clusterName, "cluster-name", builder.DefaultClusterName, "cluster name used in job names")
+	fs.StringVar(&o.jobNameTemplate, "job-name-template", builder.DefaultJobNameTemplate, "Go template for check job names")
+	fs.StringVar(&o.targetTemplate, "target-template", builder.DefaultTargetTemplate, "Go template for check targets")
+	fs.StringVar(&o.checkLabels, "labels", "", "labels added to all checks, as name=value,name2=value2")
+	fs.StringVar(&o.copyLabels, "copy-labels", "", "comma-separated list of object labels copied to checks")
+	fs.StringVar(&o.copyNamespaceLabels, "copy-namespace-labels", "", "comma-separated list of namespace labels copied to checks")
+	fs.StringVar(&o.alertSensitivity, "alert-sensitivity", "", "default alert sensitivity for checks: none, low, medium or high")
+	fs.BoolVar(&o.basicMetricsOnly, "basic-metrics-only", false, "publish only basic metrics for checks by default")
+	fs.BoolVar(&o.gatewayAPI, "gateway-api", true, "check Gateway API HTTPRoutes when the Gateway API is installed")
 
 	return fs
 }
@@ -73,6 +101,16 @@ func run(output io.Writer, args []string) (finalErr error) {
 
 	zl := setupLogger(flags.Name(), output, options)
 
+	builderOptions, err := newBuilderOptions(options)
+	if err != nil {
+		return err
+	}
+
+	configMap, err := parseConfigMapRef(options.configMap)
+	if err != nil {
+		return err
+	}
+
 	defer func() {
 		logger := zl.Info()
 		if finalErr != nil {
@@ -99,6 +137,10 @@ func run(output io.Writer, args []string) (finalErr error) {
 		return err
 	}
 
+	if err := state.RegisterMetrics(metricsRegistry); err != nil {
+		return err
+	}
+
 	readinessHandler := ops.NewReadynessHandler()
 
 	router := ops.NewMux(&ops.MuxOpts{
@@ -121,13 +163,13 @@ func run(output io.Writer, args []string) (finalErr error) {
 	})
 
 	g.Go(func() error {
-		return runController(ctx, &zl, options.kubeConfigPath, options.apiServer, options.apiToken)
+		return runController(ctx, &zl, options.kubeConfigPath, options.apiServer, options.apiToken, builderOptions, configMap, options.gatewayAPI)
 	})
 
 	// you need to call readinessHandler.Set(true) when the application is ready
 	readinessHandler.Set(true)
 
-	err := g.Wait()
+	err = g.Wait()
 
 	zl.Info().Err(err).Msg("shutting down...")
 
@@ -264,7 +306,57 @@ type runner interface {
 	Run(l net.Listener) error
 }
 
-func runController(ctx context.Context, zl *zerolog.Logger, cfgPath, apiServer, apiToken string) error {
+func newBuilderOptions(options options) (builder.Options, error) {
+	opts := builder.NewOptions()
+	opts.ClusterName = options.clusterName
+
+	var err error
+	if opts.JobNameTemplate, err = builder.ParseTemplate("job", options.jobNameTemplate); err != nil {
+		return opts, fmt.Errorf("parsing --job-name-template: %w", err)
+	}
+	if opts.TargetTemplate, err = builder.ParseTemplate("target", options.targetTemplate); err != nil {
+		return opts, fmt.Errorf("parsing --target-template: %w", err)
+	}
+
+	labels, err := builder.ParseLabels(options.checkLabels)
+	if err != nil {
+		return opts, fmt.Errorf("parsing --labels: %w", err)
+	}
+
+	opts.Labels = labels
+	opts.CopyLabels = splitFlagList(options.copyLabels)
+	opts.CopyNamespaceLabels = splitFlagList(options.copyNamespaceLabels)
+
+	if opts.AlertSensitivity, err = builder.ParseAlertSensitivity(options.alertSensitivity); err != nil {
+		return opts, fmt.Errorf("parsing --alert-sensitivity: %w", err)
+	}
+	opts.BasicMetricsOnly = options.basicMetricsOnly
+
+	return opts, nil
+}
+
+func splitFlagList(value string) (list []string) {
+	for _, elem := range strings.Split(value, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
+
+// parseConfigMapRef parses a "namespace/name" ConfigMap reference. An empty value is allowed.
+func parseConfigMapRef(value string) (ref types.NamespacedName, err error) {
+	if value == "" {
+		return ref, nil
+	}
+	namespace, name, found := strings.Cut(value, "/")
+	if !found || namespace == "" || name == "" {
+		return ref, fmt.Errorf("parsing --config-map: expected namespace/name, got %q", value)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+func runController(ctx context.Context, zl *zerolog.Logger, cfgPath, apiServer, apiToken string, builderOptions builder.Options, configMap types.NamespacedName, gatewayAPI bool) error {
 	// This should automatically fallback to in-cluster config discovery without changes.
 	config, err := clientcmd.BuildConfigFromFlags("", cfgPath)
 	if err != nil {
@@ -288,6 +380,10 @@ func runController(ctx context.Context, zl *zerolog.Logger, cfgPath, apiServer,
 	mainLogger := zl.With().Str("component", "generic-informer").Logger()
 	svcLogger := zl.With().Str("component", "service-informer").Logger()
 	ingressLogger := zl.With().Str("component", "ingress-informer").Logger()
+	nodeLogger := zl.With().Str("component", "node-informer").Logger()
+	namespaceLogger := zl.With().Str("component", "namespace-informer").Logger()
+	secretLogger := zl.With().Str("component", "secret-informer").Logger()
+	configMapLogger := zl.With().Str("component", "configmap-informer").Logger()
 	factory, err := informer.NewFactory(clientset,
 		informer.WithResyncPeriod(time.Second*60),
 		informer.WithErrorHandler(errHandler(&mainLogger)),
@@ -311,9 +407,12 @@ func runController(ctx context.Context, zl *zerolog.Logger, cfgPath, apiServer,
 	C := make(chan watchers.Event, 1)
 
 	filterUpdateNochanges := func(oldObj schema.Object, newObj schema.Object) bool {
-		return smAnnotationsChanged(oldObj, newObj) || specChanged(oldObj, newObj)
+		return smAnnotationsChanged(oldObj, newObj) || labelsChanged(oldObj, newObj) || specChanged(oldObj, newObj) ||
+			statusChanged(oldObj, newObj)
 	}
 
+	// Services and Ingresses aren't filtered by their annotations, as they can be enabled through
+	// their namespace's annotations.
 	err = iIngress.AddWatcher(
 		watchers.Chain{
 			watchers.TypeAssert[*networkingV1.Ingress]{},
@@ -342,18 +441,11 @@ func runController(ctx context.Context, zl *zerolog.Logger, cfgPath, apiServer,
 		return fmt.Errorf("creating informer for resource %s: %w", serviceRsrc, err)
 	}
 
-	hasSMAnnotation := func(obj schema.Object) bool {
-		notes := obj.GetAnnotations()
-		_, found := notes[builder.EnabledAnnotation]
-		return found
-	}
-
 	err = iService.AddWatcher(
 		watchers.Chain{
 			watchers.TypeAssert[*coreV1.Service]{},
 			watchers.ResourceMetaSetter(serviceRsrc),
 			watchers.UpdateFilter(filterUpdateNochanges),
-			watchers.Filter(hasSMAnnotation),
 			watchers.Logger{Logger: &svcLogger, Level: zerolog.DebugLevel},
 			watchers.Publisher{
 				C:   C,
@@ -365,24 +457,284 @@ func runController(ctx context.Context, zl *zerolog.Logger, cfgPath, apiServer,
 		return fmt.Errorf("registering watcher for %s resources: %w", serviceRsrc, err)
 	}
 
+	nodeRsrc := schema.Resource{
+		Group:   "",
+		Version: "v1",
+		Kind:    "Node",
+		Plural:  "nodes",
+	}
+
+	iNode, err := factory.ForResource(nodeRsrc)
+	if err != nil {
+		return fmt.Errorf("creating informer for resource %s: %w", nodeRsrc, err)
+	}
+
+	err = iNode.AddWatcher(
+		watchers.Chain{
+			watchers.TypeAssert[*coreV1.Node]{},
+			watchers.ResourceMetaSetter(nodeRsrc),
+			watchers.UpdateFilter(nodeChanged),
+			watchers.Logger{Logger: &nodeLogger, Level: zerolog.DebugLevel},
+			watchers.Publisher{
+				C:   C,
+				Ctx: ctx,
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("registering watcher for %s resources: %w", nodeRsrc, err)
+	}
+
+	namespaceRsrc := schema.Resource{
+		Group:   "",
+		Version: "v1",
+		Kind:    "Namespace",
+		Plural:  "namespaces",
+	}
+
+	iNamespace, err := factory.ForResource(namespaceRsrc)
+	if err != nil {
+		return fmt.Errorf("creating informer for resource %s: %w", namespaceRsrc, err)
+	}
+
+	err = iNamespace.AddWatcher(
+		watchers.Chain{
+			watchers.TypeAssert[*coreV1.Namespace]{},
+			watchers.ResourceMetaSetter(namespaceRsrc),
+			watchers.UpdateFilter(namespaceChanged),
+			watchers.Logger{Logger: &namespaceLogger, Level: zerolog.DebugLevel},
+			watchers.Publisher{
+				C:   C,
+				Ctx: ctx,
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("registering watcher for %s resources: %w", namespaceRsrc, err)
+	}
+
+	secretRsrc := schema.Resource{
+		Group:   "",
+		Version: "v1",
+		Kind:    "Secret",
+		Plural:  "secrets",
+	}
+
+	iSecret, err := factory.ForResource(secretRsrc)
+	if err != nil {
+		return fmt.Errorf("creating informer for resource %s: %w", secretRsrc, err)
+	}
+
+	// The Logger watcher only logs object IDs, Secret values must never be logged. Changes to Secrets
+	// and ConfigMaps that no check references are kept without triggering a sync.
+	err = iSecret.AddWatcher(
+		watchers.Chain{
+			watchers.TypeAssert[*coreV1.Secret]{},
+			watchers.ResourceMetaSetter(secretRsrc),
+			watchers.UpdateFilter(secretChanged),
+			watchers.Filter(isCredentialsSecret),
+			watchers.Logger{Logger: &secretLogger, Level: zerolog.DebugLevel},
+			watchers.Publisher{
+				C:   C,
+				Ctx: ctx,
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("registering watcher for %s resources: %w", secretRsrc, err)
+	}
+
+	configMapRsrc := schema.Resource{
+		Group:   "",
+		Version: "v1",
+		Kind:    "ConfigMap",
+		Plural:  "configmaps",
+	}
+
+	iConfigMap, err := factory.ForResource(configMapRsrc)
+	if err != nil {
+		return fmt.Errorf("creating informer for resource %s: %w", configMapRsrc, err)
+	}
+
+	// ConfigMaps can hold the scripts for k6 checks, or be the controller's config.
+	err = iConfigMap.AddWatcher(
+		watchers.Chain{
+			watchers.TypeAssert[*coreV1.ConfigMap]{},
+			watchers.ResourceMetaSetter(configMapRsrc),
+			watchers.UpdateFilter(configMapChanged),
+			watchers.Logger{Logger: &configMapLogger, Level: zerolog.DebugLevel},
+			watchers.Publisher{
+				C:   C,
+				Ctx: ctx,
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("registering watcher for %s resources: %w", configMapRsrc, err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("creating k8s dynamic client: %w", err)
+	}
+	dynamicFactory, err := informer.NewDynamicFactory(dynamicClient,
+		informer.WithResyncPeriod(time.Second*60),
+		informer.WithErrorHandler(errHandler(&mainLogger)),
+	)
+	if err != nil {
+		return fmt.Errorf("creating dynamic informer factory: %w", err)
+	}
+
+	if gatewayAPI {
+		if err := addGatewayWatchers(ctx, dynamicFactory, clientset.Discovery(), C, zl); err != nil {
+			return err
+		}
+	}
+
+	var statusWriter state.StatusWriter
+	installed, err := addSyntheticCheckWatcher(ctx, dynamicFactory, clientset.Discovery(), C, zl)
+	if err != nil {
+		return err
+	}
+	if installed {
+		statusWriter = crd.NewStatusWriter(dynamicClient)
+	}
+
+	defer dynamicFactory.Stop()
+	dynamicFactory.Start(ctx)
+
 	defer factory.Stop() // TODO: Necessary?
 	factory.Start(ctx)
 
 	pLogger := zl.With().Str("component", "publisher").Logger()
 	st := state.State{
-		C:      C,
-		Logger: zl.With().Str("component", "cluster-state").Logger(),
+		C:         C,
+		Logger:    zl.With().Str("component", "cluster-state").Logger(),
+		ConfigMap: configMap,
 		Publisher: &state.Consolidator{
 			Logger:         &pLogger,
 			ApiServer:      apiServer,
 			ApiToken:       apiToken,
 			RequestTimeout: time.Second * 30,
+			BuilderOptions: builderOptions,
+			StatusWriter:   statusWriter,
 		},
 	}
 	st.Run(ctx)
 	return nil
 }
 
+// addGatewayWatchers publishes the Gateway API Gateways and HTTPRoutes, if the Gateway API is installed
+// in the cluster.
+func addGatewayWatchers(ctx context.Context, factory *informer.Factory, disc discovery.DiscoveryInterface,
+	C chan<- watchers.Event, zl *zerolog.Logger,
+) error {
+	logger := zl.With().Str("component", "gateway-informer").Logger()
+
+	version := servedVersion(disc, gateway.Group, gateway.Versions, gateway.GatewayPlural, gateway.HTTPRoutePlural)
+	if version == "" {
+		logger.Info().Msg("Gateway API not installed, HTTPRoutes won't be checked")
+		return nil
+	}
+
+	gatewayRsrc, routeRsrc := gateway.Resources(version)
+	for _, rsrc := range []schema.Resource{gatewayRsrc, routeRsrc} {
+		if err := addDynamicWatcher(ctx, factory, rsrc, C, &logger); err != nil {
+			return err
+		}
+	}
+	logger.Info().Str("version", version).Msg("watching Gateway API resources")
+	return nil
+}
+
+// addSyntheticCheckWatcher publishes the SyntheticChecks, if their CRD is installed in the cluster.
+func addSyntheticCheckWatcher(ctx context.Context, factory *informer.Factory, disc discovery.DiscoveryInterface,
+	C chan<- watchers.Event, zl *zerolog.Logger,
+) (installed bool, err error) {
+	logger := zl.With().Str("component", "syntheticcheck-informer").Logger()
+
+	rsrc := crd.SyntheticCheckResource
+	if servedVersion(disc, rsrc.Group, []string{rsrc.Version}, rsrc.Plural) == "" {
+		logger.Info().Msg("SyntheticCheck CRD not installed")
+		return false, nil
+	}
+	return true, addDynamicWatcher(ctx, factory, rsrc, C, &logger)
+}
+
+// addDynamicWatcher publishes the changes to a resource watched through a dynamic informer. Status
+// changes are ignored, except for the addresses of Gateways.
+func addDynamicWatcher(ctx context.Context, factory *informer.Factory, rsrc schema.Resource, C chan<- watchers.Event,
+	logger *zerolog.Logger,
+) error {
+	inf, err := factory.ForResource(rsrc)
+	if err != nil {
+		return fmt.Errorf("creating informer for resource %s: %w", rsrc, err)
+	}
+	err = inf.AddWatcher(
+		watchers.Chain{
+			watchers.TypeAssert[*unstructured.Unstructured]{},
+			watchers.ResourceMetaSetter(rsrc),
+			watchers.UpdateFilter(func(oldObj, newObj schema.Object) bool {
+				return smAnnotationsChanged(oldObj, newObj) || labelsChanged(oldObj, newObj) || specChanged(oldObj, newObj) ||
+					statusChanged(oldObj, newObj)
+			}),
+			watchers.Logger{Logger: logger, Level: zerolog.DebugLevel},
+			watchers.Publisher{
+				C:   C,
+				Ctx: ctx,
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("registering watcher for %s resources: %w", rsrc, err)
+	}
+	return nil
+}
+
+// servedVersion returns the first of the given versions of an API group that serves all the given
+// resources, or an empty string if there's none.
+func servedVersion(disc discovery.DiscoveryInterface, group string, versions []string, resources ...string) string {
+	for _, version := range versions {
+		list, err := disc.ServerResourcesForGroupVersion(group + "/" + version)
+		if err != nil {
+			continue
+		}
+		served := make(map[string]bool)
+		for _, r := range list.APIResources {
+			served[r.Name] = true
+		}
+		all := true
+		for _, name := range resources {
+			all = all && served[name]
+		}
+		if all {
+			return version
+		}
+	}
+	return ""
+}
+
+// isCredentialsSecret filters the Secrets that can be referenced by checks. Other types, such as
+// service account tokens, are never used.
+func isCredentialsSecret(obj schema.Object) bool {
+	switch obj.Inner().(*coreV1.Secret).Type {
+	case coreV1.SecretTypeOpaque, coreV1.SecretTypeBasicAuth, coreV1.SecretTypeTLS:
+		return true
+	default:
+		return false
+	}
+}
+
+func secretChanged(old, new schema.Object) bool {
+	oldSecret, newSecret := old.Inner().(*coreV1.Secret), new.Inner().(*coreV1.Secret)
+	return !reflect.DeepEqual(oldSecret.Data, newSecret.Data) || !mapsEqual(oldSecret.StringData, newSecret.StringData)
+}
+
+func configMapChanged(old, new schema.Object) bool {
+	oldCM, newCM := old.Inner().(*coreV1.ConfigMap), new.Inner().(*coreV1.ConfigMap)
+	return !mapsEqual(oldCM.Data, newCM.Data) || !reflect.DeepEqual(oldCM.BinaryData, newCM.BinaryData)
+}
+
 func extractSMAnnotations(a map[string]string) map[string]string {
 	out := make(map[string]string)
 	for k, v := range a {
@@ -427,6 +779,8 @@ func getSpec(obj schema.Object) interface{} {
 		return v.Spec
 	case *networkingV1.Ingress:
 		return v.Spec
+	case *unstructured.Unstructured:
+		return v.Object["spec"]
 	default:
 		panic(fmt.Errorf("unexpected %T", v))
 	}
@@ -434,4 +788,41 @@ func getSpec(obj schema.Object) interface{} {
 
 func specChanged(old, new schema.Object) bool {
 	return !reflect.DeepEqual(getSpec(old), getSpec(new))
+}
+
+// getStatus returns the parts of an object's status that are used to build checks.
+func getStatus(obj schema.Object) interface{} {
+	switch v := obj.Inner().(type) {
+	case *coreV1.Service:
+		return v.Status.LoadBalancer
+	case *networkingV1.Ingress:
+		return v.Status.LoadBalancer
+	case *unstructured.Unstructured:
+		// Only Gateways have addresses.
+		addresses, _, _ := unstructured.NestedFieldNoCopy(v.Object, "status", "addresses")
+		return addresses
+	default:
+		return nil
+	}
+}
+
+func statusChanged(old, new schema.Object) bool {
+	return !reflect.DeepEqual(getStatus(old), getStatus(new))
+}
+
+// nodeChanged only considers the node fields used to select NodePort targets, as node status
+// is updated continuously by the kubelet.
+func nodeChanged(old, new schema.Object) bool {
+	oldNode, newNode := old.Inner().(*coreV1.Node), new.Inner().(*coreV1.Node)
+	return !reflect.DeepEqual(oldNode.Labels, newNode.Labels) ||
+		!reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses)
+}
+
+// namespaceChanged reports changes to the namespace labels and annotations used for checks.
+func namespaceChanged(old, new schema.Object) bool {
+	return labelsChanged(old, new) || smAnnotationsChanged(old, new)
+}
+
+func labelsChanged(old, new schema.Object) bool {
+	return !mapsEqual(old.Inner().(metaV1.Object).GetLabels(), new.Inner().(metaV1.Object).GetLabels())
 }
\ No newline at end of file
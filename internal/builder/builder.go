@@ -2,11 +2,16 @@ package builder
 
 import (
 	"fmt"
+	"net"
 	"strconv"
+	"strings"
 
 	coreV1 "k8s.io/api/core/v1"
 	networkingV1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/adriansr/sm-controller/internal/crd"
+	"github.com/adriansr/sm-controller/internal/gateway"
 	"github.com/adriansr/sm-controller/internal/schema"
 	"github.com/adriansr/sm-controller/internal/sm"
 )
@@ -21,39 +26,155 @@ func NewBuilder(opts Options) Builder {
 	}
 }
 
-func (b *Builder) Build(services []*coreV1.Service, ingresses []*networkingV1.Ingress) (checks []*sm.Check, warnings []Warning) {
-	if len(services) == 0 {
-		warnings = append(warnings, Warning{
-			Cause: fmt.Errorf("no services annotated for monitoring"),
-		})
-		return nil, warnings
+// Objects are the cluster resources that checks are built from.
+type Objects struct {
+	Services   []*coreV1.Service
+	Ingresses  []*networkingV1.Ingress
+	Nodes      []*coreV1.Node
+	Namespaces []*coreV1.Namespace
+	Secrets    []*coreV1.Secret
+	ConfigMaps []*coreV1.ConfigMap
+	Gateways   []*gateway.Gateway
+	HTTPRoutes []*gateway.HTTPRoute
+	// SyntheticChecks define checks explicitly, they don't need to be enabled with annotations.
+	SyntheticChecks []*crd.SyntheticCheck
+}
+
+func (b *Builder) Build(objs Objects) (checks []*sm.Check, warnings []Warning) {
+	namespaces := make(map[string]*coreV1.Namespace, len(objs.Namespaces))
+	for _, ns := range objs.Namespaces {
+		namespaces[ns.Name] = ns
 	}
 
-	for _, svc := range services {
-		svcChecks, err := b.toChecks(svc, nil)
-		if err != nil {
-			scObj, _ := schema.ObjectFrom(svc)
-			warnings = append(warnings, Warning{
-				Cause: err,
-				Objs:  []schema.Object{scObj},
-			})
-		}
+	secrets := newSecretStore(objs.Secrets)
+	configMaps := newConfigMapStore(objs.ConfigMaps)
 
+	annotated := 0
+	for _, svc := range objs.Services {
+		if !isAnnotated(svc, namespaces[svc.Namespace]) {
+			continue
+		}
+		annotated++
+		svcChecks, warns, err := b.toChecks(svc, namespaces[svc.Namespace], objs.Nodes, secrets, configMaps)
+		warnings = appendWarnings(warnings, svc, warns, err)
 		checks = append(checks, svcChecks...)
 	}
 
+	for _, ing := range objs.Ingresses {
+		if !isAnnotated(ing, namespaces[ing.Namespace]) {
+			continue
+		}
+		annotated++
+		ingChecks, warns, err := b.ingressToChecks(ing, namespaces[ing.Namespace], secrets, configMaps)
+		warnings = appendWarnings(warnings, ing, warns, err)
+		checks = append(checks, ingChecks...)
+	}
+
+	gateways := make(map[string]*gateway.Gateway, len(objs.Gateways))
+	for _, gw := range objs.Gateways {
+		gateways[gw.Namespace+"/"+gw.Name] = gw
+	}
+	for _, route := range objs.HTTPRoutes {
+		if !isAnnotated(route, namespaces[route.Namespace]) {
+			continue
+		}
+		annotated++
+		routeChecks, warns, err := b.httpRouteToChecks(route, namespaces[route.Namespace], gateways, secrets, configMaps)
+		warnings = appendWarnings(warnings, route, warns, err)
+		checks = append(checks, routeChecks...)
+	}
+
+	for _, sc := range objs.SyntheticChecks {
+		annotated++
+		check, warns, err := b.syntheticCheckToCheck(sc, namespaces[sc.Namespace], secrets, configMaps)
+		warnings = appendWarnings(warnings, sc, warns, err)
+		if check != nil {
+			checks = append(checks, check)
+		}
+	}
+
+	if annotated == 0 {
+		warnings = append(warnings, Warning{
+			Cause: fmt.Errorf("no services, ingresses, HTTP routes or synthetic checks to monitor"),
+		})
+	}
 	return checks, warnings
 }
 
+// appendWarnings adds the non-fatal warnings and the error for an object to the warnings list.
+func appendWarnings(warnings []Warning, obj interface{}, warns []error, err error) []Warning {
+	if len(warns) == 0 && err == nil {
+		return warnings
+	}
+	scObj, _ := schema.ObjectFrom(obj)
+	for _, warn := range warns {
+		warnings = append(warnings, Warning{
+			Cause: warn,
+			Objs:  []schema.Object{scObj},
+		})
+	}
+	if err != nil {
+		warnings = append(warnings, Warning{
+			Cause: err,
+			Objs:  []schema.Object{scObj},
+		})
+	}
+	return warnings
+}
+
 type Warning struct {
 	Cause error
 	Objs  []schema.Object
 }
 
-func (b *Builder) toChecks(svc *coreV1.Service, _TODO_ []*networkingV1.Ingress) (checks []*sm.Check, err error) {
-	opts := b.options.NewCheckOptions(svc.GetAnnotations())
+// prepareOptions returns the options for the checks of an object, with the secrets and ConfigMaps it
+// references resolved. Disabled objects aren't resolved, and are reported as skipped.
+func (b *Builder) prepareOptions(obj metaV1.Object, ns *coreV1.Namespace, secrets secretStore,
+	configMaps configMapStore,
+) (CheckOptions, []error, error) {
+	opts, warns := b.options.parseCheckOptions(obj, ns)
 	if !opts.Enabled {
-		return nil, nil
+		return opts, append(warns, &Skipped{Reason: SkipDisabled}), nil
+	}
+	resolveWarns, err := b.resolveOptions(&opts, obj, ns, secrets, configMaps)
+	return opts, append(warns, resolveWarns...), err
+}
+
+// resolveOptions validates the HTTP options, resolves the secrets and ConfigMaps referenced by the
+// options of an object and checks its labels.
+func (b *Builder) resolveOptions(opts *CheckOptions, obj metaV1.Object, ns *coreV1.Namespace, secrets secretStore,
+	configMaps configMapStore,
+) (warns []error, err error) {
+	if err := opts.HTTP.validate(); err != nil {
+		return nil, err
+	}
+	if err := opts.HTTP.resolveAuth(obj.GetNamespace(), secrets); err != nil {
+		return nil, err
+	}
+	warns = opts.TLS.resolve(obj.GetNamespace(), secrets)
+	if err := opts.K6.resolve(obj.GetNamespace(), configMaps); err != nil {
+		warns = append(warns, err)
+	}
+	var labelWarns []error
+	opts.Labels, labelWarns = b.options.checkLabels(opts.Labels, obj, ns)
+	return append(warns, labelWarns...), nil
+}
+
+func (b *Builder) toChecks(svc *coreV1.Service, ns *coreV1.Namespace, nodes []*coreV1.Node, secrets secretStore, configMaps configMapStore) (checks []*sm.Check, warns []error, err error) {
+	opts, warns, err := b.prepareOptions(svc, ns, secrets, configMaps)
+	if err != nil || !opts.Enabled {
+		return nil, warns, err
+	}
+
+	if svc.Spec.Type == coreV1.ServiceTypeExternalName && svc.Spec.ExternalName != "" {
+		if err := opts.DNS.validate(); err != nil {
+			return nil, warns, err
+		}
+		check, err := opts.dnsCheckForHost(svc, svc.Spec.ExternalName, nil)
+		if err != nil {
+			return nil, warns, err
+		}
+		checks = append(checks, check)
 	}
 
 	var hosts []string
@@ -64,70 +185,173 @@ func (b *Builder) toChecks(svc *coreV1.Service, _TODO_ []*networkingV1.Ingress)
 		for _, ip := range svc.Spec.ExternalIPs {
 			hosts = append(hosts, ip)
 		}
+		if len(hosts) == 0 {
+			hosts = serviceLoadBalancerAddresses(svc)
+		}
 	}
 
-	for _, host := range hosts {
+	// NodePort services without other addresses are checked through the nodes' addresses.
+	useNodePort := len(hosts) == 0 && svc.Spec.Type == coreV1.ServiceTypeNodePort
+	if useNodePort {
+		if hosts, err = opts.Nodes.selectAddresses(svc, nodes); err != nil {
+			return nil, warns, err
+		}
+	}
+
+	if len(hosts) == 0 {
+		if len(checks) == 0 {
+			warns = append(warns, &Skipped{Reason: SkipNoHost})
+		}
+		return checks, warns, nil
+	}
+
+	var ports []coreV1.ServicePort
+	if opts.Ping.Mode != PingOnly {
 		for _, port := range svc.Spec.Ports {
-			check, err := opts.checkForHostPort(svc, host, port)
+			if skip := opts.skipPort(port, useNodePort); skip != nil {
+				warns = append(warns, skip)
+				continue
+			}
+			ports = append(ports, port)
+		}
+	}
+
+	for _, host := range hosts {
+		if opts.Traceroute.Enabled {
+			check, err := opts.tracerouteCheckForHost(svc, host)
 			if err != nil {
-				return nil, err
+				return nil, warns, err
 			}
 			checks = append(checks, check)
 		}
+		if opts.K6.enabled() {
+			check, err := opts.k6CheckForHost(svc, "http", host)
+			if err != nil {
+				return nil, warns, err
+			}
+			checks = append(checks, check)
+		}
+		for _, family := range opts.familiesFor(svc.Spec.IPFamilies, host) {
+			familyOpts := opts.forFamily(family)
+			if familyOpts.Ping.Mode != PingDisabled {
+				check, err := familyOpts.pingCheckForHost(svc, host)
+				if err != nil {
+					return nil, warns, err
+				}
+				checks = append(checks, check)
+			}
+			for _, port := range ports {
+				portOpts := familyOpts.forPort(port)
+				if useNodePort {
+					port.Port = port.NodePort
+				}
+				check, err := portOpts.checkForHostPort(svc, host, port)
+				if err != nil {
+					return nil, warns, err
+				}
+				checks = append(checks, check)
+			}
+		}
 	}
-	return checks, nil
+	return checks, warns, nil
 }
 
-func (opts *CheckOptions) checkForHostPort(svc *coreV1.Service, host string, port coreV1.ServicePort) (*sm.Check, error) {
-	check := &sm.Check{
+// skipPort returns why no checks are created for a port, or nil if the port must be checked.
+func (opts *CheckOptions) skipPort(port coreV1.ServicePort, useNodePort bool) *Skipped {
+	portName := port.Name
+	if portName == "" {
+		portName = strconv.Itoa(int(port.Port))
+	}
+	switch {
+	case !opts.portSelected(port):
+		return &Skipped{Reason: SkipPortNotSelected, Port: portName}
+	case port.Protocol != coreV1.ProtocolTCP:
+		return &Skipped{Reason: SkipUnsupportedProtocol, Port: portName, Detail: string(port.Protocol)}
+	case useNodePort && port.NodePort == 0:
+		return &Skipped{Reason: SkipNoNodePort, Port: portName}
+	default:
+		return nil
+	}
+}
+
+// serviceLoadBalancerAddresses returns the IPs and hostnames of the load balancers in the Service status.
+func serviceLoadBalancerAddresses(svc *coreV1.Service) (addresses []string) {
+	for _, lb := range svc.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			addresses = append(addresses, lb.IP)
+		}
+		if lb.Hostname != "" {
+			addresses = append(addresses, lb.Hostname)
+		}
+	}
+	return addresses
+}
+
+// newCheck returns a check for target with the common fields set from the options.
+func (opts *CheckOptions) newCheck(target string) *sm.Check {
+	return &sm.Check{
 		RawCheck: sm.RawCheck{
 			Enabled:   true,
 			Frequency: opts.Frequency,
 			Timeout:   opts.Timeout,
-			Labels:    opts.Labels, // TODO: + other labels
+			Labels:    append([]sm.Label(nil), opts.Labels...),
 			Job:       opts.JobName,
-		},
+			Target:    target,
 
-		Probes: opts.Probes, // Override
+			BasicMetricsOnly: opts.BasicMetricsOnly,
+			AlertSensitivity: opts.AlertSensitivity,
+		},
 
-		// TODO: BasicMetricsOnly: false,
-		// TODO: AlertSensitivity: "",
+		Probes:     opts.Probes, // Override
+		ProbeCount: opts.ProbeCount,
 	}
+}
+
+func (opts *CheckOptions) checkForHostPort(svc *coreV1.Service, host string, port coreV1.ServicePort) (*sm.Check, error) {
+	check := opts.newCheck("")
 
 	portName := port.Name
 	if portName == "" {
 		portName = strconv.Itoa(int(port.Port))
 	}
-	if check.Job == "" {
-		check.Job = fmt.Sprintf("%s_%s/%s_%s:%s/%s",
-			"k8s", // TODO: Context
-			svc.Namespace,
-			svc.Name,
-			host,
-			portName,
-			port.Protocol,
-		)
-	}
-	switch port.Protocol {
+	protocol := string(port.Protocol)
+	if opts.Port.isHTTP() && port.Protocol == coreV1.ProtocolTCP {
+		protocol = strings.ToUpper(opts.Port.Type)
+	}
+	switch protocol {
 	case "TCP":
-		check.Settings.Tcp = &sm.TcpSettings{
-			IpVersion: sm.IpVersion_V4,
-		}
-	case "UDP", "SCTP":
-		// TODO: Ignorable error for logging
-		return nil, nil
+		check.Settings.Tcp = opts.tcpSettings()
+	case "HTTP", "HTTPS":
+		check.Settings.Http = opts.httpSettings()
+	default:
+		return nil, &Skipped{Reason: SkipUnsupportedProtocol, Port: portName, Detail: protocol}
 	}
 
-	switch {
-	case opts.Target != "":
-		check.Target = opts.Target
-
-	case opts.Host != "":
+	if opts.Host != "" {
 		host = opts.Host
-		fallthrough
-	default:
-		check.Target = fmt.Sprintf("%s:%d", host, port.Port)
+	}
+	check.Target = net.JoinHostPort(host, strconv.Itoa(int(port.Port)))
+	if check.Settings.Http != nil {
+		check.Target = fmt.Sprintf("%s://%s%s", opts.Port.Type, check.Target, opts.Port.Path)
+	}
+
+	if err := opts.nameCheck(check, svc, JobData{Host: host, Port: portName, Protocol: protocol}); err != nil {
+		return nil, err
+	}
+	if opts.Target != "" {
+		check.Target = opts.Target
 	}
 
 	return check, nil
 }
+
+func (opts *CheckOptions) tcpSettings() *sm.TcpSettings {
+	settings := &sm.TcpSettings{
+		IpVersion: opts.Family.Version,
+	}
+	if opts.TLS.TCP {
+		settings.Tls = true
+		settings.TlsConfig = opts.TLS.config()
+	}
+	return settings
+}
@@ -0,0 +1,1481 @@
+package builder
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	sm "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	"github.com/stretchr/testify/require"
+	coreV1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIngressChecks(t *testing.T) {
+	for name, test := range map[string]struct {
+		ingress *networkingV1.Ingress
+		targets []string
+		jobs    []string
+		skips   []SkipReason
+		warns   []string
+	}{
+		"not enabled": {
+			ingress: &networkingV1.Ingress{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+					Annotations: map[string]string{
+						EnabledAnnotation: "false",
+					},
+				},
+				Spec: networkingV1.IngressSpec{
+					Rules: []networkingV1.IngressRule{
+						{Host: "example.com"},
+					},
+				},
+			},
+			skips: []SkipReason{SkipDisabled},
+		},
+		"rules and paths": {
+			ingress: &networkingV1.Ingress{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+					Annotations: map[string]string{
+						EnabledAnnotation: "true",
+					},
+				},
+				Spec: networkingV1.IngressSpec{
+					TLS: []networkingV1.IngressTLS{
+						{Hosts: []string{"secure.example.com"}},
+					},
+					Rules: []networkingV1.IngressRule{
+						{Host: "example.com"},
+						{
+							Host: "secure.example.com",
+							IngressRuleValue: networkingV1.IngressRuleValue{
+								HTTP: &networkingV1.HTTPIngressRuleValue{
+									Paths: []networkingV1.HTTPIngressPath{
+										{Path: "/api"},
+										{Path: ""},
+									},
+								},
+							},
+						},
+						{},
+					},
+				},
+			},
+			targets: []string{
+				"http://example.com/",
+				"https://secure.example.com/api",
+				"https://secure.example.com/",
+			},
+			jobs: []string{
+				"k8s_default/web_http://example.com/",
+				"k8s_default/web_https://secure.example.com/api",
+				"k8s_default/web_https://secure.example.com/",
+			},
+		},
+		"host override": {
+			ingress: &networkingV1.Ingress{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "web",
+					Namespace: "other",
+					Annotations: map[string]string{
+						EnabledAnnotation: "true",
+						HostAnnotation:    "www.example.com",
+					},
+				},
+				Spec: networkingV1.IngressSpec{
+					Rules: []networkingV1.IngressRule{
+						{},
+					},
+				},
+			},
+			targets: []string{"http://www.example.com/"},
+			jobs:    []string{"k8s_other/web_http://www.example.com/"},
+		},
+		"wildcard host": {
+			ingress: &networkingV1.Ingress{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+					Annotations: map[string]string{
+						EnabledAnnotation: "true",
+					},
+				},
+				Spec: networkingV1.IngressSpec{
+					Rules: []networkingV1.IngressRule{
+						{Host: "*.example.com"},
+						{Host: "www.example.com"},
+					},
+				},
+			},
+			targets: []string{"http://www.example.com/"},
+			jobs:    []string{"k8s_default/web_http://www.example.com/"},
+			warns:   []string{"wildcard hostname *.example.com can't be checked"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{Ingresses: []*networkingV1.Ingress{test.ingress}})
+			skips, others := splitWarnings(warns)
+			require.Equal(t, test.skips, skips)
+			var messages []string
+			for _, err := range others {
+				messages = append(messages, err.Error())
+			}
+			require.Equal(t, test.warns, messages)
+			var targets, jobs []string
+			for _, check := range checks {
+				require.NotNil(t, check.Settings.Http)
+				targets = append(targets, check.Target)
+				jobs = append(jobs, check.Job)
+			}
+			require.Equal(t, test.targets, targets)
+			require.Equal(t, test.jobs, jobs)
+		})
+	}
+}
+
+func TestIngressAssertionWarnings(t *testing.T) {
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		warns       bool
+	}{
+		"valid": {
+			annotations: map[string]string{
+				HTTPFailIfBodyMatchesAnnotation:   "(?i)error\nmaintenance",
+				HTTPFailIfHeaderMatchesAnnotation: "Server: ^nginx/1\\.[0-9]+$",
+				HTTPFailIfNotSSLAnnotation:        "true",
+			},
+		},
+		"bad body regexp": {
+			annotations: map[string]string{
+				HTTPFailIfBodyNotMatchesAnnotation: "ok(",
+			},
+			warns: true,
+		},
+		"bad header regexp": {
+			annotations: map[string]string{
+				HTTPFailIfHeaderNotMatchesAnnotation: "Content-Type: [a-z",
+			},
+			warns: true,
+		},
+		"header match without name": {
+			annotations: map[string]string{
+				HTTPFailIfHeaderMatchesAnnotation: "text/html",
+			},
+			warns: true,
+		},
+		"conflicting ssl": {
+			annotations: map[string]string{
+				HTTPFailIfSSLAnnotation:    "true",
+				HTTPFailIfNotSSLAnnotation: "true",
+			},
+			warns: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{
+				EnabledAnnotation: "true",
+			}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			ing := &networkingV1.Ingress{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        "web",
+					Namespace:   "default",
+					Annotations: annotations,
+				},
+				Spec: networkingV1.IngressSpec{
+					Rules: []networkingV1.IngressRule{
+						{Host: "example.com"},
+					},
+				},
+			}
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{Ingresses: []*networkingV1.Ingress{ing}})
+			if !test.warns {
+				require.Empty(t, warns)
+				require.Len(t, checks, 1)
+				return
+			}
+			require.Empty(t, checks)
+			require.Len(t, warns, 1)
+			require.Len(t, warns[0].Objs, 1)
+			require.Equal(t, ing, warns[0].Objs[0].Inner())
+		})
+	}
+}
+
+func TestServiceHostChecks(t *testing.T) {
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		jobs        []string
+	}{
+		"tcp only": {
+			jobs: []string{
+				"k8s_default/db_10.0.0.1:pg/TCP",
+				"k8s_default/db_10.0.0.2:pg/TCP",
+			},
+		},
+		"ping also": {
+			annotations: map[string]string{
+				PingAnnotation:            "true",
+				PingPacketCountAnnotation: "3",
+			},
+			jobs: []string{
+				"k8s_default/db_10.0.0.1/ICMP",
+				"k8s_default/db_10.0.0.1:pg/TCP",
+				"k8s_default/db_10.0.0.2/ICMP",
+				"k8s_default/db_10.0.0.2:pg/TCP",
+			},
+		},
+		"ping only": {
+			annotations: map[string]string{
+				PingAnnotation: "only",
+			},
+			jobs: []string{
+				"k8s_default/db_10.0.0.1/ICMP",
+				"k8s_default/db_10.0.0.2/ICMP",
+			},
+		},
+		"traceroute": {
+			annotations: map[string]string{
+				TracerouteAnnotation:        "true",
+				TracerouteMaxHopsAnnotation: "30",
+				FrequencyAnnotation:         "10000",
+			},
+			jobs: []string{
+				"k8s_default/db_10.0.0.1/traceroute",
+				"k8s_default/db_10.0.0.1:pg/TCP",
+				"k8s_default/db_10.0.0.2/traceroute",
+				"k8s_default/db_10.0.0.2:pg/TCP",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{
+				EnabledAnnotation: "true",
+			}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			svc := &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        "db",
+					Namespace:   "default",
+					Annotations: annotations,
+				},
+				Spec: coreV1.ServiceSpec{
+					ExternalIPs: []string{"10.0.0.1", "10.0.0.2"},
+					Ports: []coreV1.ServicePort{
+						{Name: "pg", Port: 5432, Protocol: coreV1.ProtocolTCP},
+					},
+				},
+			}
+			opts := NewOptions()
+			b := NewBuilder(opts)
+			checks, warns := b.Build(Objects{Services: []*coreV1.Service{svc}})
+			require.Empty(t, warns)
+			var jobs []string
+			for _, check := range checks {
+				jobs = append(jobs, check.Job)
+				if check.Settings.Ping != nil {
+					require.Contains(t, svc.Spec.ExternalIPs, check.Target)
+					require.Equal(t, opts.NewCheckOptions(annotations).Ping.PacketCount, check.Settings.Ping.PacketCount)
+				}
+				if check.Settings.Traceroute != nil {
+					require.Equal(t, int64(120000), check.Frequency)
+					require.Equal(t, int64(30000), check.Timeout)
+					require.Equal(t, int64(30), check.Settings.Traceroute.MaxHops)
+				}
+			}
+			require.Equal(t, test.jobs, jobs)
+		})
+	}
+}
+
+func TestDNSChecks(t *testing.T) {
+	t.Run("external name service", func(t *testing.T) {
+		svc := &coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      "upstream",
+				Namespace: "default",
+				Annotations: map[string]string{
+					EnabledAnnotation:       "true",
+					DNSRecordTypeAnnotation: "cname",
+					DNSServerAnnotation:     "1.1.1.1",
+				},
+			},
+			Spec: coreV1.ServiceSpec{
+				Type:         coreV1.ServiceTypeExternalName,
+				ExternalName: "api.example.com",
+			},
+		}
+		b := NewBuilder(NewOptions())
+		checks, warns := b.Build(Objects{Services: []*coreV1.Service{svc}})
+		require.Empty(t, warns)
+		require.Len(t, checks, 1)
+		require.Equal(t, "api.example.com", checks[0].Target)
+		require.Equal(t, "k8s_default/upstream_api.example.com/DNS", checks[0].Job)
+		require.NotNil(t, checks[0].Settings.Dns)
+		require.Equal(t, sm.DnsRecordType_CNAME, checks[0].Settings.Dns.RecordType)
+		require.Equal(t, "1.1.1.1", checks[0].Settings.Dns.Server)
+		require.Nil(t, checks[0].Settings.Dns.ValidateAnswer)
+	})
+
+	t.Run("invalid answer regexp", func(t *testing.T) {
+		svc := &coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      "upstream",
+				Namespace: "default",
+				Annotations: map[string]string{
+					EnabledAnnotation:            "true",
+					DNSExpectedAnswersAnnotation: "(",
+					AnnotationsPrefix + "probez": "Paris",
+				},
+			},
+			Spec: coreV1.ServiceSpec{
+				Type:         coreV1.ServiceTypeExternalName,
+				ExternalName: "api.example.com",
+			},
+		}
+		b := NewBuilder(NewOptions())
+		checks, warns := b.Build(Objects{Services: []*coreV1.Service{svc}})
+		require.Empty(t, checks)
+		// The annotation problems are reported along with the error.
+		require.Len(t, warns, 2)
+		require.EqualError(t, warns[0].Cause, "unknown annotation synthetics.grafana.com/probez")
+		require.ErrorContains(t, warns[1].Cause, "invalid answer regexp")
+	})
+
+	t.Run("ingress hosts", func(t *testing.T) {
+		ing := &networkingV1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      "web",
+				Namespace: "default",
+				Annotations: map[string]string{
+					EnabledAnnotation:               "true",
+					DNSAnnotation:                   "true",
+					DNSExpectLoadBalancerAnnotation: "true",
+				},
+			},
+			Spec: networkingV1.IngressSpec{
+				Rules: []networkingV1.IngressRule{
+					{
+						Host: "example.com",
+						IngressRuleValue: networkingV1.IngressRuleValue{
+							HTTP: &networkingV1.HTTPIngressRuleValue{
+								Paths: []networkingV1.HTTPIngressPath{
+									{Path: "/a"},
+									{Path: "/b"},
+								},
+							},
+						},
+					},
+				},
+			},
+			Status: networkingV1.IngressStatus{
+				LoadBalancer: networkingV1.IngressLoadBalancerStatus{
+					Ingress: []networkingV1.IngressLoadBalancerIngress{
+						{IP: "203.0.113.10"},
+					},
+				},
+			},
+		}
+		b := NewBuilder(NewOptions())
+		checks, warns := b.Build(Objects{Ingresses: []*networkingV1.Ingress{ing}})
+		require.Empty(t, warns)
+		require.Len(t, checks, 3)
+		dns := checks[0].Settings.Dns
+		require.NotNil(t, dns)
+		require.Equal(t, "example.com", checks[0].Target)
+		require.NotNil(t, dns.ValidateAnswer)
+		require.Len(t, dns.ValidateAnswer.FailIfNotMatchesRegexp, 1)
+		expr := regexp.MustCompile(dns.ValidateAnswer.FailIfNotMatchesRegexp[0])
+		require.True(t, expr.MatchString("example.com.\t300\tIN\tA\t203.0.113.10"))
+		require.False(t, expr.MatchString("example.com.\t300\tIN\tA\t203.0.113.100"))
+	})
+}
+
+func TestServiceLoadBalancerHosts(t *testing.T) {
+	for name, test := range map[string]struct {
+		spec    coreV1.ServiceSpec
+		status  coreV1.ServiceStatus
+		targets []string
+		skips   []SkipReason
+	}{
+		"external IPs take precedence": {
+			spec: coreV1.ServiceSpec{
+				Type:        coreV1.ServiceTypeLoadBalancer,
+				ExternalIPs: []string{"10.0.0.1"},
+			},
+			status: coreV1.ServiceStatus{
+				LoadBalancer: coreV1.LoadBalancerStatus{
+					Ingress: []coreV1.LoadBalancerIngress{{IP: "203.0.113.1"}},
+				},
+			},
+			targets: []string{"10.0.0.1:443"},
+		},
+		"load balancer addresses": {
+			spec: coreV1.ServiceSpec{
+				Type: coreV1.ServiceTypeLoadBalancer,
+			},
+			status: coreV1.ServiceStatus{
+				LoadBalancer: coreV1.LoadBalancerStatus{
+					Ingress: []coreV1.LoadBalancerIngress{
+						{IP: "203.0.113.1"},
+						{Hostname: "lb.example.com"},
+					},
+				},
+			},
+			targets: []string{"203.0.113.1:443", "lb.example.com:443"},
+		},
+		"not provisioned": {
+			spec: coreV1.ServiceSpec{
+				Type: coreV1.ServiceTypeLoadBalancer,
+			},
+			skips: []SkipReason{SkipNoHost},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			svc := &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+					Annotations: map[string]string{
+						EnabledAnnotation: "true",
+					},
+				},
+				Spec:   test.spec,
+				Status: test.status,
+			}
+			svc.Spec.Ports = []coreV1.ServicePort{
+				{Name: "https", Port: 443, Protocol: coreV1.ProtocolTCP},
+			}
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{Services: []*coreV1.Service{svc}})
+			require.Equal(t, test.skips, skipReasons(t, warns))
+			var targets []string
+			for _, check := range checks {
+				targets = append(targets, check.Target)
+			}
+			require.Equal(t, test.targets, targets)
+		})
+	}
+}
+
+func TestNodePortChecks(t *testing.T) {
+	node := func(name, addr string, labels map[string]string) *coreV1.Node {
+		return &coreV1.Node{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:   name,
+				Labels: labels,
+			},
+			Status: coreV1.NodeStatus{
+				Addresses: []coreV1.NodeAddress{
+					{Type: coreV1.NodeInternalIP, Address: "192.168.0." + addr},
+					{Type: coreV1.NodeExternalIP, Address: "198.51.100." + addr},
+				},
+			},
+		}
+	}
+	nodes := []*coreV1.Node{
+		node("n1", "1", map[string]string{"role": "edge"}),
+		node("n2", "2", map[string]string{"role": "edge"}),
+		node("n3", "3", nil),
+		node("n4", "4", nil),
+	}
+
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		targets     []string
+		numTargets  int
+		warns       bool
+	}{
+		"default single node": {
+			numTargets: 1,
+		},
+		"all nodes": {
+			annotations: map[string]string{
+				NodeCountAnnotation: "all",
+			},
+			numTargets: 4,
+		},
+		"selector": {
+			annotations: map[string]string{
+				NodeCountAnnotation:    "all",
+				NodeSelectorAnnotation: "role=edge",
+			},
+			targets: []string{"198.51.100.1:30080", "198.51.100.2:30080"},
+		},
+		"internal addresses": {
+			annotations: map[string]string{
+				NodeCountAnnotation:       "all",
+				NodeSelectorAnnotation:    "role notin (edge)",
+				NodeAddressTypeAnnotation: "InternalIP",
+			},
+			targets: []string{"192.168.0.3:30080", "192.168.0.4:30080"},
+		},
+		"bad selector": {
+			annotations: map[string]string{
+				NodeSelectorAnnotation: "role in edge",
+			},
+			warns: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{
+				EnabledAnnotation: "true",
+			}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			svc := &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        "web",
+					Namespace:   "default",
+					Annotations: annotations,
+				},
+				Spec: coreV1.ServiceSpec{
+					Type: coreV1.ServiceTypeNodePort,
+					Ports: []coreV1.ServicePort{
+						{Name: "http", Port: 80, NodePort: 30080, Protocol: coreV1.ProtocolTCP},
+					},
+				},
+			}
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{Services: []*coreV1.Service{svc}, Nodes: nodes})
+			if test.warns {
+				require.Len(t, warns, 1)
+				require.Empty(t, checks)
+				return
+			}
+			require.Empty(t, warns)
+			var targets []string
+			for _, check := range checks {
+				targets = append(targets, check.Target)
+			}
+			if test.targets != nil {
+				require.ElementsMatch(t, test.targets, targets)
+			} else {
+				require.Len(t, targets, test.numTargets)
+			}
+
+			again, _ := b.Build(Objects{Services: []*coreV1.Service{svc}, Nodes: nodes})
+			require.Equal(t, checks, again)
+		})
+	}
+}
+
+func TestServicePorts(t *testing.T) {
+	type portCheck struct {
+		target    string
+		frequency int64
+		timeout   int64
+		http      bool
+	}
+
+	for name, test := range map[string]struct {
+		config      map[string]string
+		annotations map[string]string
+		expected    map[string]portCheck
+		skips       []SkipReason
+	}{
+		"all ports": {
+			expected: map[string]portCheck{
+				"k8s_default/web_10.0.0.1:https/TCP":   {target: "10.0.0.1:443", frequency: 60000, timeout: 3000},
+				"k8s_default/web_10.0.0.1:metrics/TCP": {target: "10.0.0.1:9090", frequency: 60000, timeout: 3000},
+				"k8s_default/web_10.0.0.1:8080/TCP":    {target: "10.0.0.1:8080", frequency: 60000, timeout: 3000},
+			},
+		},
+		"selection and overrides": {
+			annotations: map[string]string{
+				PortsAnnotation:                          "https, 8080",
+				PortAnnotationsPrefix + "https.type":     "HTTPS",
+				PortAnnotationsPrefix + "https.path":     "/healthz",
+				PortAnnotationsPrefix + "https.timeout":  "5000",
+				PortAnnotationsPrefix + "443.timeout":    "1000",
+				PortAnnotationsPrefix + "8080.frequency": "30000",
+				PortAnnotationsPrefix + "metrics.type":   "http",
+			},
+			expected: map[string]portCheck{
+				"k8s_default/web_10.0.0.1:https/HTTPS": {target: "https://10.0.0.1:443/healthz", frequency: 60000, timeout: 5000, http: true},
+				"k8s_default/web_10.0.0.1:8080/TCP":    {target: "10.0.0.1:8080", frequency: 30000, timeout: 3000},
+			},
+			skips: []SkipReason{SkipPortNotSelected},
+		},
+		"config overrides": {
+			config: map[string]string{
+				"port.https.type":     "https",
+				"port.https.path":     "/",
+				"port.8080.frequency": "30s",
+			},
+			annotations: map[string]string{
+				PortAnnotationsPrefix + "https.path": "/healthz",
+			},
+			expected: map[string]portCheck{
+				"k8s_default/web_10.0.0.1:https/HTTPS": {target: "https://10.0.0.1:443/healthz", frequency: 60000, timeout: 3000, http: true},
+				"k8s_default/web_10.0.0.1:metrics/TCP": {target: "10.0.0.1:9090", frequency: 60000, timeout: 3000},
+				"k8s_default/web_10.0.0.1:8080/TCP":    {target: "10.0.0.1:8080", frequency: 30000, timeout: 3000},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{
+				EnabledAnnotation: "true",
+			}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			svc := &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        "web",
+					Namespace:   "default",
+					Annotations: annotations,
+				},
+				Spec: coreV1.ServiceSpec{
+					ExternalIPs: []string{"10.0.0.1"},
+					Ports: []coreV1.ServicePort{
+						{Name: "https", Port: 443, Protocol: coreV1.ProtocolTCP},
+						{Name: "metrics", Port: 9090, Protocol: coreV1.ProtocolTCP},
+						{Port: 8080, Protocol: coreV1.ProtocolTCP},
+					},
+				},
+			}
+			opts, err := ParseConfig(NewOptions(), test.config)
+			require.NoError(t, err)
+			b := NewBuilder(opts)
+			checks, warns := b.Build(Objects{Services: []*coreV1.Service{svc}})
+			require.Equal(t, test.skips, skipReasons(t, warns))
+			require.Len(t, checks, len(test.expected))
+			for _, check := range checks {
+				expected, found := test.expected[check.Job]
+				require.True(t, found, check.Job)
+				require.Equal(t, expected.target, check.Target)
+				require.Equal(t, expected.frequency, check.Frequency)
+				require.Equal(t, expected.timeout, check.Timeout)
+				require.Equal(t, expected.http, check.Settings.Http != nil)
+				require.Equal(t, !expected.http, check.Settings.Tcp != nil)
+			}
+		})
+	}
+}
+
+func TestServiceIPFamilies(t *testing.T) {
+	type familyCheck struct {
+		job     string
+		target  string
+		version sm.IpVersion
+	}
+
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		families    []coreV1.IPFamily
+		hosts       []string
+		expected    []familyCheck
+	}{
+		"address literals": {
+			hosts: []string{"10.0.0.1", "2001:db8::1"},
+			expected: []familyCheck{
+				{job: "k8s_default/web_10.0.0.1:http/TCP", target: "10.0.0.1:80", version: sm.IpVersion_V4},
+				{job: "k8s_default/web_2001:db8::1:http/TCP", target: "[2001:db8::1]:80", version: sm.IpVersion_V6},
+			},
+		},
+		"single stack IPv6 hostname": {
+			families: []coreV1.IPFamily{coreV1.IPv6Protocol},
+			hosts:    []string{"lb.example.com"},
+			expected: []familyCheck{
+				{job: "k8s_default/web_lb.example.com:http/TCP", target: "lb.example.com:80", version: sm.IpVersion_V6},
+			},
+		},
+		"dual stack hostname": {
+			families: []coreV1.IPFamily{coreV1.IPv4Protocol, coreV1.IPv6Protocol},
+			hosts:    []string{"lb.example.com"},
+			expected: []familyCheck{
+				{job: "k8s_default/web_lb.example.com:http/TCP", target: "lb.example.com:80", version: sm.IpVersion_Any},
+			},
+		},
+		"dual stack hostname per family": {
+			annotations: map[string]string{
+				IPVersionPerFamilyAnnotation: "true",
+				PingAnnotation:               "true",
+			},
+			families: []coreV1.IPFamily{coreV1.IPv4Protocol, coreV1.IPv6Protocol},
+			hosts:    []string{"lb.example.com"},
+			expected: []familyCheck{
+				{job: "k8s_default/web_lb.example.com/ICMP/IPv4", target: "lb.example.com", version: sm.IpVersion_V4},
+				{job: "k8s_default/web_lb.example.com:http/TCP/IPv4", target: "lb.example.com:80", version: sm.IpVersion_V4},
+				{job: "k8s_default/web_lb.example.com/ICMP/IPv6", target: "lb.example.com", version: sm.IpVersion_V6},
+				{job: "k8s_default/web_lb.example.com:http/TCP/IPv6", target: "lb.example.com:80", version: sm.IpVersion_V6},
+			},
+		},
+		"explicit version": {
+			annotations: map[string]string{
+				IPVersionAnnotation:          "any",
+				IPVersionPerFamilyAnnotation: "true",
+			},
+			families: []coreV1.IPFamily{coreV1.IPv4Protocol, coreV1.IPv6Protocol},
+			hosts:    []string{"10.0.0.1"},
+			expected: []familyCheck{
+				{job: "k8s_default/web_10.0.0.1:http/TCP", target: "10.0.0.1:80", version: sm.IpVersion_Any},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{
+				EnabledAnnotation: "true",
+			}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			svc := &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        "web",
+					Namespace:   "default",
+					Annotations: annotations,
+				},
+				Spec: coreV1.ServiceSpec{
+					IPFamilies:  test.families,
+					ExternalIPs: test.hosts,
+					Ports: []coreV1.ServicePort{
+						{Name: "http", Port: 80, Protocol: coreV1.ProtocolTCP},
+					},
+				},
+			}
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{Services: []*coreV1.Service{svc}})
+			require.Empty(t, warns)
+			var result []familyCheck
+			for _, check := range checks {
+				version := sm.IpVersion(-1)
+				switch {
+				case check.Settings.Tcp != nil:
+					version = check.Settings.Tcp.IpVersion
+				case check.Settings.Ping != nil:
+					version = check.Settings.Ping.IpVersion
+				}
+				result = append(result, familyCheck{job: check.Job, target: check.Target, version: version})
+			}
+			require.Equal(t, test.expected, result)
+		})
+	}
+}
+
+func TestSkippedPorts(t *testing.T) {
+	svc := &coreV1.Service{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "dns",
+			Namespace: "default",
+			Annotations: map[string]string{
+				EnabledAnnotation: "true",
+			},
+		},
+		Spec: coreV1.ServiceSpec{
+			Type: coreV1.ServiceTypeNodePort,
+			Ports: []coreV1.ServicePort{
+				{Name: "dns-udp", Port: 53, NodePort: 30053, Protocol: coreV1.ProtocolUDP},
+				{Name: "dns-tcp", Port: 53, NodePort: 30054, Protocol: coreV1.ProtocolTCP},
+				{Name: "sctp", Port: 9899, NodePort: 30055, Protocol: coreV1.ProtocolSCTP},
+				{Name: "other", Port: 8080, Protocol: coreV1.ProtocolTCP},
+			},
+		},
+	}
+	nodes := []*coreV1.Node{
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "n1"},
+			Status: coreV1.NodeStatus{
+				Addresses: []coreV1.NodeAddress{
+					{Type: coreV1.NodeExternalIP, Address: "198.51.100.1"},
+				},
+			},
+		},
+	}
+
+	b := NewBuilder(NewOptions())
+	checks, warns := b.Build(Objects{Services: []*coreV1.Service{svc}, Nodes: nodes})
+	require.Len(t, checks, 1)
+	require.NotNil(t, checks[0])
+	require.Equal(t, "198.51.100.1:30054", checks[0].Target)
+
+	require.Len(t, warns, 3)
+	var skipped []Skipped
+	for _, w := range warns {
+		var skip *Skipped
+		require.ErrorAs(t, w.Cause, &skip)
+		require.Len(t, w.Objs, 1)
+		require.Equal(t, svc, w.Objs[0].Inner())
+		skipped = append(skipped, *skip)
+	}
+	require.Equal(t, []Skipped{
+		{Reason: SkipUnsupportedProtocol, Port: "dns-udp", Detail: "UDP"},
+		{Reason: SkipUnsupportedProtocol, Port: "sctp", Detail: "SCTP"},
+		{Reason: SkipNoNodePort, Port: "other"},
+	}, skipped)
+	require.Equal(t, "skipped port dns-udp: unsupported-protocol (UDP)", warns[0].Cause.Error())
+}
+
+// skipReasons returns the reasons of the skipped warnings, failing if there are other warnings.
+func skipReasons(t *testing.T, warns []Warning) []SkipReason {
+	t.Helper()
+	reasons, others := splitWarnings(warns)
+	require.Empty(t, others)
+	return reasons
+}
+
+// splitWarnings returns the reasons of the skipped objects and the causes of the other warnings.
+func splitWarnings(warns []Warning) (reasons []SkipReason, others []error) {
+	for _, w := range warns {
+		var skip *Skipped
+		if errors.As(w.Cause, &skip) {
+			reasons = append(reasons, skip.Reason)
+		} else {
+			others = append(others, w.Cause)
+		}
+	}
+	return reasons, others
+}
+
+func TestCheckLabels(t *testing.T) {
+	ns := &coreV1.Namespace{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: "shop",
+			Labels: map[string]string{
+				"team":                "payments",
+				"tier":                "gold",
+				"kubernetes.io/owner": "alice",
+			},
+		},
+	}
+
+	for name, test := range map[string]struct {
+		annotation string
+		labels     map[string]string
+		expected   []sm.Label
+		warns      int
+	}{
+		"controller and namespace": {
+			expected: []sm.Label{
+				{Name: "cluster", Value: "prod"},
+				{Name: "kubernetes_io_owner", Value: "alice"},
+				{Name: "team", Value: "payments"},
+				{Name: "tier", Value: "gold"},
+			},
+		},
+		"precedence": {
+			annotation: "tier=silver,env=prod",
+			labels: map[string]string{
+				"team":  "checkout",
+				"other": "ignored",
+			},
+			expected: []sm.Label{
+				{Name: "cluster", Value: "prod"},
+				{Name: "env", Value: "prod"},
+				{Name: "kubernetes_io_owner", Value: "alice"},
+				{Name: "team", Value: "checkout"},
+				{Name: "tier", Value: "silver"},
+			},
+		},
+		"invalid": {
+			annotation: "bad-name=x,novalue,managed_by=me,ok=1",
+			expected: []sm.Label{
+				{Name: "cluster", Value: "prod"},
+				{Name: "kubernetes_io_owner", Value: "alice"},
+				{Name: "ok", Value: "1"},
+				{Name: "team", Value: "payments"},
+				{Name: "tier", Value: "gold"},
+			},
+			warns: 3,
+		},
+		"limit": {
+			annotation: "a=1,b=2,c=3,d=4,e=5,f=6",
+			expected: []sm.Label{
+				{Name: "a", Value: "1"},
+				{Name: "b", Value: "2"},
+				{Name: "c", Value: "3"},
+				{Name: "d", Value: "4"},
+				{Name: "e", Value: "5"},
+				{Name: "f", Value: "6"},
+				{Name: "kubernetes_io_owner", Value: "alice"},
+				{Name: "team", Value: "payments"},
+				{Name: "tier", Value: "gold"},
+			},
+			warns: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{
+				EnabledAnnotation: "true",
+			}
+			if test.annotation != "" {
+				annotations[LabelsAnnotation] = test.annotation
+			}
+			svc := &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        "web",
+					Namespace:   "shop",
+					Labels:      test.labels,
+					Annotations: annotations,
+				},
+				Spec: coreV1.ServiceSpec{
+					ExternalIPs: []string{"10.0.0.1"},
+					Ports: []coreV1.ServicePort{
+						{Name: "http", Port: 80, Protocol: coreV1.ProtocolTCP},
+					},
+				},
+			}
+			opts := NewOptions()
+			opts.Labels = []sm.Label{{Name: "cluster", Value: "prod"}}
+			opts.CopyLabels = []string{"team"}
+			opts.CopyNamespaceLabels = []string{"team", "tier", "kubernetes.io/owner"}
+			b := NewBuilder(opts)
+			checks, warns := b.Build(Objects{
+				Services:   []*coreV1.Service{svc},
+				Namespaces: []*coreV1.Namespace{ns},
+			})
+			require.Len(t, warns, test.warns)
+			require.Len(t, checks, 1)
+			require.Equal(t, test.expected, checks[0].Labels)
+		})
+	}
+}
+
+func TestJobTemplates(t *testing.T) {
+	svc := &coreV1.Service{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "web",
+			Namespace: "shop",
+			Annotations: map[string]string{
+				EnabledAnnotation: "true",
+				PingAnnotation:    "true",
+			},
+		},
+		Spec: coreV1.ServiceSpec{
+			ExternalIPs: []string{"10.0.0.1"},
+			Ports: []coreV1.ServicePort{
+				{Name: "http", Port: 80, Protocol: coreV1.ProtocolTCP},
+			},
+		},
+	}
+	ing := &networkingV1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "web",
+			Namespace: "shop",
+			Annotations: map[string]string{
+				EnabledAnnotation: "true",
+			},
+		},
+		Spec: networkingV1.IngressSpec{
+			Rules: []networkingV1.IngressRule{
+				{Host: "example.com"},
+			},
+		},
+	}
+
+	for name, test := range map[string]struct {
+		cluster string
+		job     string
+		target  string
+		// expected maps job names to targets.
+		expected map[string]string
+	}{
+		"defaults": {
+			expected: map[string]string{
+				"k8s_shop/web_10.0.0.1/ICMP":       "10.0.0.1",
+				"k8s_shop/web_10.0.0.1:http/TCP":   "10.0.0.1:80",
+				"k8s_shop/web_http://example.com/": "http://example.com/",
+			},
+		},
+		"cluster name": {
+			cluster: "eu-west",
+			expected: map[string]string{
+				"eu-west_shop/web_10.0.0.1/ICMP":       "10.0.0.1",
+				"eu-west_shop/web_10.0.0.1:http/TCP":   "10.0.0.1:80",
+				"eu-west_shop/web_http://example.com/": "http://example.com/",
+			},
+		},
+		"custom templates": {
+			cluster: "eu-west",
+			job:     `{{.Cluster}}/{{.Kind}}/{{.Namespace}}/{{.Name}}/{{.Protocol}}{{with .Port}}/{{.}}{{end}}{{.Path}}`,
+			target:  `{{if eq .Protocol "ICMP"}}{{.Name}}.{{.Namespace}}.svc{{else}}{{.Target}}{{end}}`,
+			expected: map[string]string{
+				"eu-west/Service/shop/web/ICMP":     "web.shop.svc",
+				"eu-west/Service/shop/web/TCP/http": "10.0.0.1:80",
+				"eu-west/Ingress/shop/web/HTTP/":    "http://example.com/",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := NewOptions()
+			opts.ClusterName = test.cluster
+			var err error
+			if test.job != "" {
+				opts.JobNameTemplate, err = ParseTemplate("job", test.job)
+				require.NoError(t, err)
+			}
+			if test.target != "" {
+				opts.TargetTemplate, err = ParseTemplate("target", test.target)
+				require.NoError(t, err)
+			}
+			b := NewBuilder(opts)
+			checks, warns := b.Build(Objects{
+				Services:  []*coreV1.Service{svc},
+				Ingresses: []*networkingV1.Ingress{ing},
+			})
+			require.Empty(t, warns)
+			result := make(map[string]string)
+			for _, check := range checks {
+				result[check.Job] = check.Target
+			}
+			require.Equal(t, test.expected, result)
+		})
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	for name, text := range map[string]string{
+		"syntax error":  "{{.Name",
+		"unknown field": "{{.Cluster}}_{{.Unknown}}",
+		"empty":         `{{if false}}{{.Name}}{{end}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTemplate("job", text)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestNamespaceDefaults(t *testing.T) {
+	newService := func(name string, annotations map[string]string) *coreV1.Service {
+		return &coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:        name,
+				Namespace:   "shop",
+				Annotations: annotations,
+			},
+			Spec: coreV1.ServiceSpec{
+				ExternalIPs: []string{"10.0.0.1"},
+				Ports: []coreV1.ServicePort{
+					{Name: "http", Port: 80, Protocol: coreV1.ProtocolTCP},
+				},
+			},
+		}
+	}
+
+	for name, test := range map[string]struct {
+		namespace   map[string]string
+		annotations map[string]string
+		checks      int
+		frequency   int64
+		probes      []string
+		labels      []sm.Label
+		skips       []SkipReason
+	}{
+		"not annotated": {},
+		"enabled by namespace": {
+			namespace: map[string]string{
+				EnabledAnnotation:   "true",
+				FrequencyAnnotation: "30000",
+				ProbesAnnotation:    "Paris",
+				LabelsAnnotation:    "team=payments,tier=gold",
+				"example.com/other": "ignored",
+			},
+			checks:    1,
+			frequency: 30000,
+			probes:    []string{"Paris"},
+			labels: []sm.Label{
+				{Name: "team", Value: "payments"},
+				{Name: "tier", Value: "gold"},
+			},
+		},
+		"overridden by object": {
+			namespace: map[string]string{
+				EnabledAnnotation:   "true",
+				FrequencyAnnotation: "30000",
+				LabelsAnnotation:    "team=payments,tier=gold",
+			},
+			annotations: map[string]string{
+				FrequencyAnnotation: "10000",
+				LabelsAnnotation:    "tier=silver",
+			},
+			checks:    1,
+			frequency: 10000,
+			probes:    defaultCheckOptions.Probes,
+			labels: []sm.Label{
+				{Name: "team", Value: "payments"},
+				{Name: "tier", Value: "silver"},
+			},
+		},
+		"disabled by object": {
+			namespace: map[string]string{
+				EnabledAnnotation: "true",
+			},
+			annotations: map[string]string{
+				EnabledAnnotation: "false",
+			},
+			skips: []SkipReason{SkipDisabled},
+		},
+		"disabled by namespace": {
+			namespace: map[string]string{
+				EnabledAnnotation: "false",
+			},
+			skips: []SkipReason{SkipDisabled},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ns := &coreV1.Namespace{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        "shop",
+					Annotations: test.namespace,
+				},
+			}
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{
+				Services: []*coreV1.Service{
+					newService("web", test.annotations),
+					newService("enabled", map[string]string{EnabledAnnotation: "true"}),
+				},
+				Namespaces: []*coreV1.Namespace{ns},
+			})
+			require.Equal(t, test.skips, skipReasons(t, warns))
+
+			result := checks[:0:0]
+			for _, check := range checks {
+				if check.Job != "k8s_shop/enabled_10.0.0.1:http/TCP" {
+					result = append(result, check)
+				}
+			}
+			require.Len(t, result, test.checks)
+			for _, check := range result {
+				require.Equal(t, test.frequency, check.Frequency)
+				require.Equal(t, test.probes, check.Probes)
+				require.Equal(t, test.labels, check.Labels)
+			}
+		})
+	}
+}
+
+func TestHTTPAuth(t *testing.T) {
+	secrets := []*coreV1.Secret{
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web-auth", Namespace: "shop"},
+			Type:       coreV1.SecretTypeBasicAuth,
+			Data: map[string][]byte{
+				coreV1.BasicAuthUsernameKey: []byte("admin"),
+				coreV1.BasicAuthPasswordKey: []byte("s3cr3t"),
+			},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web-token", Namespace: "shop"},
+			Data: map[string][]byte{
+				BearerTokenKey: []byte("t0k3n"),
+			},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "other-token", Namespace: "other"},
+			Data: map[string][]byte{
+				BearerTokenKey: []byte("t0k3n"),
+			},
+		},
+	}
+
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		basicAuth   *sm.BasicAuth
+		bearerToken string
+		err         bool
+	}{
+		"no auth": {},
+		"basic auth": {
+			annotations: map[string]string{
+				BasicAuthSecretAnnotation: "web-auth",
+			},
+			basicAuth: &sm.BasicAuth{Username: "admin", Password: "s3cr3t"},
+		},
+		"bearer token": {
+			annotations: map[string]string{
+				BearerTokenSecretAnnotation: "web-token",
+			},
+			bearerToken: "t0k3n",
+		},
+		"missing key": {
+			annotations: map[string]string{
+				BearerTokenSecretAnnotation: "web-auth",
+			},
+			err: true,
+		},
+		"other namespace": {
+			annotations: map[string]string{
+				BearerTokenSecretAnnotation: "other-token",
+			},
+			err: true,
+		},
+		"both": {
+			annotations: map[string]string{
+				BasicAuthSecretAnnotation:   "web-auth",
+				BearerTokenSecretAnnotation: "web-token",
+			},
+			err: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{
+				EnabledAnnotation: "true",
+			}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			ing := &networkingV1.Ingress{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        "web",
+					Namespace:   "shop",
+					Annotations: annotations,
+				},
+				Spec: networkingV1.IngressSpec{
+					Rules: []networkingV1.IngressRule{
+						{Host: "example.com"},
+					},
+				},
+			}
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{
+				Ingresses: []*networkingV1.Ingress{ing},
+				Secrets:   secrets,
+			})
+			if test.err {
+				require.Empty(t, checks)
+				require.Len(t, warns, 1)
+				return
+			}
+			require.Empty(t, warns)
+			require.Len(t, checks, 1)
+			require.Equal(t, test.basicAuth, checks[0].Settings.Http.BasicAuth)
+			require.Equal(t, test.bearerToken, checks[0].Settings.Http.BearerToken)
+		})
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	secrets := []*coreV1.Secret{
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "mtls", Namespace: "shop"},
+			Type:       coreV1.SecretTypeTLS,
+			Data: map[string][]byte{
+				TLSCAKey:                []byte("ca"),
+				coreV1.TLSCertKey:       []byte("cert"),
+				coreV1.TLSPrivateKeyKey: []byte("key"),
+			},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "ca-only", Namespace: "shop"},
+			Data: map[string][]byte{
+				TLSCAKey: []byte("ca"),
+			},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "no-key", Namespace: "shop"},
+			Data: map[string][]byte{
+				TLSCAKey:          []byte("ca"),
+				coreV1.TLSCertKey: []byte("cert"),
+			},
+		},
+	}
+
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		http        *sm.TLSConfig
+		tcp         *sm.TLSConfig
+		tcpTLS      bool
+		warns       int
+	}{
+		"none": {},
+		"mutual TLS": {
+			annotations: map[string]string{
+				TLSSecretAnnotation:     "mtls",
+				TLSServerNameAnnotation: "web.internal",
+			},
+			http: &sm.TLSConfig{
+				CACert:     []byte("ca"),
+				ClientCert: []byte("cert"),
+				ClientKey:  []byte("key"),
+				ServerName: "web.internal",
+			},
+		},
+		"tcp": {
+			annotations: map[string]string{
+				TLSSecretAnnotation:             "ca-only",
+				TLSInsecureSkipVerifyAnnotation: "true",
+				TCPTLSAnnotation:                "true",
+			},
+			http: &sm.TLSConfig{
+				CACert:             []byte("ca"),
+				InsecureSkipVerify: true,
+			},
+			tcp: &sm.TLSConfig{
+				CACert:             []byte("ca"),
+				InsecureSkipVerify: true,
+			},
+			tcpTLS: true,
+		},
+		"missing key": {
+			annotations: map[string]string{
+				TLSSecretAnnotation: "no-key",
+			},
+			http:  &sm.TLSConfig{CACert: []byte("ca")},
+			warns: 1,
+		},
+		"missing secret": {
+			annotations: map[string]string{
+				TLSSecretAnnotation: "missing",
+			},
+			warns: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{
+				EnabledAnnotation:                    "true",
+				PortAnnotationsPrefix + "https.type": "https",
+			}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			svc := &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        "web",
+					Namespace:   "shop",
+					Annotations: annotations,
+				},
+				Spec: coreV1.ServiceSpec{
+					ExternalIPs: []string{"10.0.0.1"},
+					Ports: []coreV1.ServicePort{
+						{Name: "https", Port: 443, Protocol: coreV1.ProtocolTCP},
+						{Name: "db", Port: 5432, Protocol: coreV1.ProtocolTCP},
+					},
+				},
+			}
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{
+				Services: []*coreV1.Service{svc},
+				Secrets:  secrets,
+			})
+			require.Len(t, warns, test.warns)
+			require.Len(t, checks, 2)
+			for _, check := range checks {
+				switch {
+				case check.Settings.Http != nil:
+					require.Equal(t, test.http, check.Settings.Http.TlsConfig)
+				case check.Settings.Tcp != nil:
+					require.Equal(t, test.tcpTLS, check.Settings.Tcp.Tls)
+					require.Equal(t, test.tcp, check.Settings.Tcp.TlsConfig)
+				default:
+					t.Fatalf("unexpected check %s", check.Job)
+				}
+			}
+		})
+	}
+}
+
+func TestK6Checks(t *testing.T) {
+	configMaps := []*coreV1.ConfigMap{
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "scripts", Namespace: "shop"},
+			Data: map[string]string{
+				K6ScriptKey:   "export default function() {}",
+				"checkout.js": "export default function() { checkout() }",
+			},
+		},
+	}
+	svc := &coreV1.Service{
+		ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "shop"},
+		Spec: coreV1.ServiceSpec{
+			ExternalIPs: []string{"10.0.0.1", "2001:db8::1"},
+			Ports:       []coreV1.ServicePort{{Name: "http", Port: 80, Protocol: coreV1.ProtocolTCP}},
+		},
+	}
+	ing := &networkingV1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "shop"},
+		Spec: networkingV1.IngressSpec{
+			TLS: []networkingV1.IngressTLS{{Hosts: []string{"shop.example.com"}}},
+			Rules: []networkingV1.IngressRule{
+				{Host: "shop.example.com"},
+				{Host: "shop.example.com", IngressRuleValue: networkingV1.IngressRuleValue{
+					HTTP: &networkingV1.HTTPIngressRuleValue{Paths: []networkingV1.HTTPIngressPath{{Path: "/cart"}}},
+				}},
+			},
+		},
+	}
+
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		expected    map[string]string // Job to script.
+		warns       int
+	}{
+		"default key": {
+			annotations: map[string]string{
+				K6ScriptConfigMapAnnotation: "scripts",
+			},
+			expected: map[string]string{
+				"k8s_shop/web_10.0.0.1/k6":         "export default function() {}",
+				"k8s_shop/web_2001:db8::1/k6":      "export default function() {}",
+				"k8s_shop/web_shop.example.com/k6": "export default function() {}",
+			},
+		},
+		"key": {
+			annotations: map[string]string{
+				K6ScriptConfigMapAnnotation: "scripts",
+				K6ScriptKeyAnnotation:       "checkout.js",
+			},
+			expected: map[string]string{
+				"k8s_shop/web_10.0.0.1/k6":         "export default function() { checkout() }",
+				"k8s_shop/web_2001:db8::1/k6":      "export default function() { checkout() }",
+				"k8s_shop/web_shop.example.com/k6": "export default function() { checkout() }",
+			},
+		},
+		"missing key": {
+			annotations: map[string]string{
+				K6ScriptConfigMapAnnotation: "scripts",
+				K6ScriptKeyAnnotation:       "login.js",
+			},
+			expected: map[string]string{},
+			warns:    2,
+		},
+		"missing configmap": {
+			annotations: map[string]string{
+				K6ScriptConfigMapAnnotation: "other",
+			},
+			expected: map[string]string{},
+			warns:    2,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{EnabledAnnotation: "true"}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			svc, ing := svc.DeepCopy(), ing.DeepCopy()
+			svc.Annotations, ing.Annotations = annotations, annotations
+
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{
+				Services:   []*coreV1.Service{svc},
+				Ingresses:  []*networkingV1.Ingress{ing},
+				ConfigMaps: configMaps,
+			})
+			require.Len(t, warns, test.warns)
+
+			scripts := make(map[string]string)
+			for _, check := range checks {
+				if k6 := check.Settings.K6; k6 != nil {
+					scripts[check.Job] = string(k6.Script)
+					require.Contains(t, []string{"http://10.0.0.1/", "http://[2001:db8::1]/", "https://shop.example.com/"}, check.Target)
+				}
+			}
+			require.Equal(t, test.expected, scripts)
+		})
+	}
+}
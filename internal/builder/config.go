@@ -0,0 +1,96 @@
+package builder
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Controller settings in the config ConfigMap. Any other key sets the default for the annotation
+// of the same name without the prefix, such as "frequency" or "probes".
+const (
+	ConfigClusterName         = "cluster-name"
+	ConfigJobNameTemplate     = "job-name-template"
+	ConfigTargetTemplate      = "target-template"
+	ConfigLabels              = "labels"
+	ConfigCopyLabels          = "copy-labels"
+	ConfigCopyNamespaceLabels = "copy-namespace-labels"
+	ConfigAlertSensitivity    = "alert-sensitivity"
+	ConfigBasicMetricsOnly    = "basic-metrics-only"
+)
+
+// objectOnlyAnnotations can't have a default value, as they identify or enable a single object.
+var objectOnlyAnnotations = map[string]bool{
+	EnabledAnnotation: true,
+	NameAnnotation:    true,
+	HostAnnotation:    true,
+}
+
+// ParseConfig returns the options resulting from applying the settings in a config ConfigMap's data
+// to base. Settings that aren't in the config keep their value in base.
+func ParseConfig(base Options, data map[string]string) (Options, error) {
+	opts := base
+	defaults := make(map[string]string)
+	var err error
+
+	for key, value := range data {
+		switch key {
+		case ConfigClusterName:
+			opts.ClusterName = value
+
+		case ConfigJobNameTemplate:
+			if opts.JobNameTemplate, err = ParseTemplate("job", value); err != nil {
+				return base, fmt.Errorf("invalid %s: %w", key, err)
+			}
+
+		case ConfigTargetTemplate:
+			if opts.TargetTemplate, err = ParseTemplate("target", value); err != nil {
+				return base, fmt.Errorf("invalid %s: %w", key, err)
+			}
+
+		case ConfigLabels:
+			if opts.Labels, err = ParseLabels(value); err != nil {
+				return base, fmt.Errorf("invalid %s: %w", key, err)
+			}
+
+		case ConfigCopyLabels:
+			opts.CopyLabels = splitList(value)
+
+		case ConfigCopyNamespaceLabels:
+			opts.CopyNamespaceLabels = splitList(value)
+
+		case ConfigAlertSensitivity:
+			if opts.AlertSensitivity, err = ParseAlertSensitivity(value); err != nil {
+				return base, fmt.Errorf("invalid %s: %w", key, err)
+			}
+
+		case ConfigBasicMetricsOnly:
+			if opts.BasicMetricsOnly, err = strconv.ParseBool(value); err != nil {
+				return base, fmt.Errorf("invalid %s: %w", key, err)
+			}
+
+		default:
+			annotation := AnnotationsPrefix + key
+			if objectOnlyAnnotations[annotation] {
+				return base, fmt.Errorf("%s can't be set in the config", key)
+			}
+			defaults[annotation] = value
+		}
+	}
+
+	if errs := validateAnnotations(defaults); len(errs) > 0 {
+		return base, fmt.Errorf("invalid default: %w", errs[0])
+	}
+
+	checkDefaults := base.defaults
+	if len(defaults) > 0 {
+		checkDefaults = base.NewCheckOptions(defaults)
+		if err := checkDefaults.HTTP.validate(); err != nil {
+			return base, err
+		}
+		if err := checkDefaults.DNS.validate(); err != nil {
+			return base, err
+		}
+	}
+	opts.defaults = checkDefaults
+	return opts, nil
+}
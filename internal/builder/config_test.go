@@ -0,0 +1,105 @@
+package builder
+
+import (
+	"testing"
+
+	sm "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	"github.com/stretchr/testify/require"
+	networkingV1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseConfig(t *testing.T) {
+	base := NewOptions()
+	base.ClusterName = "from-flags"
+	base.CopyLabels = []string{"team"}
+
+	t.Run("empty", func(t *testing.T) {
+		opts, err := ParseConfig(base, nil)
+		require.NoError(t, err)
+		require.Equal(t, base.ClusterName, opts.ClusterName)
+		require.Equal(t, base.CopyLabels, opts.CopyLabels)
+		require.Equal(t, base.defaults, opts.defaults)
+	})
+
+	t.Run("settings", func(t *testing.T) {
+		opts, err := ParseConfig(base, map[string]string{
+			ConfigClusterName:         "prod",
+			ConfigLabels:              "env=prod",
+			ConfigCopyNamespaceLabels: "owner, tier",
+			ConfigAlertSensitivity:    "high",
+			ConfigBasicMetricsOnly:    "true",
+			ConfigJobNameTemplate:     "{{.Cluster}}/{{.Name}}/{{.Protocol}}",
+			"frequency":               "30000",
+			"probes":                  "Frankfurt,Tokyo",
+			"http-method":             "HEAD",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "prod", opts.ClusterName)
+		require.Equal(t, []sm.Label{{Name: "env", Value: "prod"}}, opts.Labels)
+		require.Equal(t, base.CopyLabels, opts.CopyLabels)
+		require.Equal(t, []string{"owner", "tier"}, opts.CopyNamespaceLabels)
+		require.NotNil(t, opts.JobNameTemplate)
+
+		checkOpts := opts.NewCheckOptions(map[string]string{
+			TimeoutAnnotation: "5000",
+		})
+		require.Equal(t, int64(30000), checkOpts.Frequency)
+		require.Equal(t, int64(5000), checkOpts.Timeout)
+		require.Equal(t, []string{"Frankfurt", "Tokyo"}, checkOpts.Probes)
+		require.Equal(t, sm.HttpMethod_HEAD, checkOpts.HTTP.Method)
+		require.Equal(t, "high", checkOpts.AlertSensitivity)
+		require.True(t, checkOpts.BasicMetricsOnly)
+
+		// Annotations still override the config defaults.
+		checkOpts = opts.NewCheckOptions(map[string]string{
+			FrequencyAnnotation: "10000",
+			ProbesAnnotation:    "Paris",
+		})
+		require.Equal(t, int64(10000), checkOpts.Frequency)
+		require.Equal(t, []string{"Paris"}, checkOpts.Probes)
+	})
+
+	t.Run("status codes", func(t *testing.T) {
+		opts, err := ParseConfig(base, map[string]string{"http-valid-status-codes": "200,201,202"})
+		require.NoError(t, err)
+
+		b := NewBuilder(opts)
+		var ingresses []*networkingV1.Ingress
+		for _, codes := range []string{"301", "404", ""} {
+			ingresses = append(ingresses, &networkingV1.Ingress{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "web-" + codes,
+					Namespace: "default",
+					Annotations: map[string]string{
+						EnabledAnnotation:              "true",
+						HTTPValidStatusCodesAnnotation: codes,
+					},
+				},
+				Spec: networkingV1.IngressSpec{
+					Rules: []networkingV1.IngressRule{{Host: "example.com"}},
+				},
+			})
+		}
+		checks, _ := b.Build(Objects{Ingresses: ingresses})
+		var codes [][]int32
+		for _, check := range checks {
+			codes = append(codes, check.Settings.Http.ValidStatusCodes)
+		}
+		require.Equal(t, [][]int32{{301}, {404}, {200, 201, 202}}, codes)
+	})
+
+	for name, data := range map[string]map[string]string{
+		"invalid template":    {ConfigJobNameTemplate: "{{.Nope}}"},
+		"invalid labels":      {ConfigLabels: "bad-name=1"},
+		"invalid sensitivity": {ConfigAlertSensitivity: "extreme"},
+		"invalid bool":        {ConfigBasicMetricsOnly: "yes please"},
+		"invalid regexp":      {"http-fail-if-body-matches-regexp": "("},
+		"object only setting": {"enabled": "true"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseConfig(base, data)
+			require.Error(t, err)
+		})
+	}
+}
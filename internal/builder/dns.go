@@ -0,0 +1,108 @@
+package builder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+type DNSOptions struct {
+	// Enabled controls if DNS checks are created for Ingress hosts.
+	Enabled    bool
+	RecordType sm.DnsRecordType
+	Server     string
+	Port       int32
+	Protocol   sm.DnsProtocol
+	IpVersion  sm.IpVersion
+
+	ExpectedAnswers    []string
+	ExpectLoadBalancer bool
+}
+
+func parseDNSOptions(annotations map[string]string, opts DNSOptions) DNSOptions {
+	if enabled, err := strconv.ParseBool(annotations[DNSAnnotation]); err == nil {
+		opts.Enabled = enabled
+	}
+	if recordType, found := parseDnsRecordType(annotations[DNSRecordTypeAnnotation]); found {
+		opts.RecordType = recordType
+	}
+	if server := annotations[DNSServerAnnotation]; server != "" {
+		opts.Server = server
+	}
+	if port, err := strconv.ParseUint(annotations[DNSPortAnnotation], 10, 16); err == nil && port > 0 {
+		opts.Port = int32(port)
+	}
+	if protocol, found := parseDnsProtocol(annotations[DNSProtocolAnnotation]); found {
+		opts.Protocol = protocol
+	}
+	if version, found := parseIpVersion(annotations[DNSIPVersionAnnotation]); found {
+		opts.IpVersion = version
+	}
+	if answers := splitLines(annotations[DNSExpectedAnswersAnnotation]); len(answers) > 0 {
+		opts.ExpectedAnswers = answers
+	}
+	if expectLB, err := strconv.ParseBool(annotations[DNSExpectLoadBalancerAnnotation]); err == nil {
+		opts.ExpectLoadBalancer = expectLB
+	}
+	return opts
+}
+
+func (opts *DNSOptions) validate() error {
+	for _, expr := range opts.ExpectedAnswers {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid answer regexp in %s: %w", DNSExpectedAnswersAnnotation, err)
+		}
+	}
+	return nil
+}
+
+// loadBalancerAnswerRegexp returns an expression that matches an answer record pointing to
+// any of the given addresses.
+func loadBalancerAnswerRegexp(addresses []string) string {
+	quoted := make([]string, len(addresses))
+	for idx, addr := range addresses {
+		quoted[idx] = regexp.QuoteMeta(strings.TrimSuffix(addr, "."))
+	}
+	return fmt.Sprintf(`(^|\s)(%s)\.?$`, strings.Join(quoted, "|"))
+}
+
+// dnsCheckForHost creates a DNS check for host. lbAddresses are the load balancer addresses
+// that the answer is expected to contain, if requested in the options.
+func (opts *CheckOptions) dnsCheckForHost(obj metaV1.Object, host string, lbAddresses []string) (*sm.Check, error) {
+	check := opts.newCheck(host)
+	if err := opts.nameCheck(check, obj, JobData{Host: host, Protocol: "DNS"}); err != nil {
+		return nil, err
+	}
+
+	check.Settings.Dns = opts.dnsSettings(lbAddresses)
+
+	return check, nil
+}
+
+// dnsSettings returns the settings for DNS checks. lbAddresses are the load balancer addresses that
+// the answer is expected to contain, if requested in the options.
+func (opts *CheckOptions) dnsSettings(lbAddresses []string) *sm.DnsSettings {
+	settings := &sm.DnsSettings{
+		RecordType: opts.DNS.RecordType,
+		Server:     opts.DNS.Server,
+		Port:       opts.DNS.Port,
+		Protocol:   opts.DNS.Protocol,
+		IpVersion:  opts.DNS.IpVersion,
+	}
+
+	answers := opts.DNS.ExpectedAnswers
+	if opts.DNS.ExpectLoadBalancer && len(lbAddresses) > 0 {
+		answers = append(answers[:len(answers):len(answers)], loadBalancerAnswerRegexp(lbAddresses))
+	}
+	if len(answers) > 0 {
+		settings.ValidateAnswer = &sm.DNSRRValidator{
+			FailIfNotMatchesRegexp: answers,
+		}
+	}
+	return settings
+}
@@ -0,0 +1,194 @@
+package builder
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+
+	"github.com/adriansr/sm-controller/internal/gateway"
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+func (b *Builder) httpRouteToChecks(route *gateway.HTTPRoute, ns *coreV1.Namespace, gateways map[string]*gateway.Gateway,
+	secrets secretStore, configMaps configMapStore,
+) (checks []*sm.Check, warns []error, err error) {
+	opts, warns, err := b.prepareOptions(route, ns, secrets, configMaps)
+	if err != nil || !opts.Enabled {
+		return nil, warns, err
+	}
+	if err := opts.DNS.validate(); err != nil {
+		return nil, warns, err
+	}
+
+	endpoints, addresses, routeWarns := httpRouteEndpoints(route, gateways, opts.Host)
+	warns = append(warns, routeWarns...)
+	if len(endpoints) == 0 {
+		return nil, append(warns, &Skipped{Reason: SkipNoHost}), nil
+	}
+
+	seen := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		if seen[endpoint.Host] {
+			continue
+		}
+		seen[endpoint.Host] = true
+		if opts.DNS.Enabled && net.ParseIP(endpoint.Host) == nil {
+			check, err := opts.dnsCheckForHost(route, endpoint.Host, addresses)
+			if err != nil {
+				return nil, warns, err
+			}
+			checks = append(checks, check)
+		}
+		if opts.K6.enabled() {
+			check, err := opts.k6CheckForHost(route, endpoint.Scheme, endpoint.Host)
+			if err != nil {
+				return nil, warns, err
+			}
+			checks = append(checks, check)
+		}
+	}
+	for _, endpoint := range endpoints {
+		endpointOpts := opts.forFamily(opts.familiesFor(nil, endpoint.Host)[0])
+		check, err := endpointOpts.checkForHTTPEndpoint(route, endpoint)
+		if err != nil {
+			return nil, warns, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, warns, nil
+}
+
+// httpRouteEndpoints returns the endpoints of an HTTPRoute through the HTTP and HTTPS listeners of its
+// parent Gateways, and the addresses of those Gateways. Routes and listeners without hostnames use
+// defaultHost, or the Gateway's addresses when it's empty.
+func httpRouteEndpoints(route *gateway.HTTPRoute, gateways map[string]*gateway.Gateway, defaultHost string) (
+	endpoints []httpEndpoint, addresses []string, warns []error,
+) {
+	paths, warns := httpRoutePaths(route)
+	for _, host := range route.Spec.Hostnames {
+		if strings.HasPrefix(host, "*") {
+			warns = append(warns, fmt.Errorf("wildcard hostname %s can't be checked", host))
+		}
+	}
+
+	seenEndpoints := make(map[httpEndpoint]bool)
+	seenAddresses := make(map[string]bool)
+	for _, ref := range route.Spec.ParentRefs {
+		if !ref.IsGateway() {
+			continue
+		}
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = route.Namespace
+		}
+		gw, found := gateways[namespace+"/"+ref.Name]
+		if !found {
+			warns = append(warns, fmt.Errorf("gateway %s/%s not found", namespace, ref.Name))
+			continue
+		}
+
+		var gwAddresses []string
+		for _, address := range gw.Status.Addresses {
+			gwAddresses = append(gwAddresses, address.Value)
+			if !seenAddresses[address.Value] {
+				seenAddresses[address.Value] = true
+				addresses = append(addresses, address.Value)
+			}
+		}
+
+		for _, listener := range gw.Spec.Listeners {
+			if (ref.SectionName != "" && ref.SectionName != listener.Name) || (ref.Port != 0 && ref.Port != listener.Port) {
+				continue
+			}
+			scheme := strings.ToLower(listener.Protocol)
+			if scheme != "http" && scheme != "https" {
+				continue
+			}
+			hosts := listenerHostnames(route.Spec.Hostnames, listener.Hostname)
+			if len(hosts) == 0 && len(route.Spec.Hostnames) == 0 && listener.Hostname == "" {
+				if defaultHost != "" {
+					hosts = []string{defaultHost}
+				} else {
+					hosts = gwAddresses
+				}
+			}
+			port := listener.Port
+			if (scheme == "http" && port == 80) || (scheme == "https" && port == 443) {
+				port = 0
+			}
+			for _, host := range hosts {
+				for _, path := range paths {
+					endpoint := httpEndpoint{Scheme: scheme, Host: host, Port: port, Path: path}
+					if !seenEndpoints[endpoint] {
+						seenEndpoints[endpoint] = true
+						endpoints = append(endpoints, endpoint)
+					}
+				}
+			}
+		}
+	}
+	return endpoints, addresses, warns
+}
+
+// listenerHostnames returns the hostnames of a route that are accepted by a listener. Wildcard
+// hostnames are only matched, as they can't be checked.
+func listenerHostnames(routeHosts []string, listenerHost string) (hosts []string) {
+	if len(routeHosts) == 0 {
+		if listenerHost != "" && !strings.HasPrefix(listenerHost, "*") {
+			hosts = append(hosts, listenerHost)
+		}
+		return hosts
+	}
+	for _, host := range routeHosts {
+		switch {
+		case strings.HasPrefix(host, "*"):
+			if listenerHost != "" && !strings.HasPrefix(listenerHost, "*") && wildcardMatches(host, listenerHost) {
+				hosts = append(hosts, listenerHost)
+			}
+		case listenerHost == "" || listenerHost == host || wildcardMatches(listenerHost, host):
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// wildcardMatches returns whether a hostname matches a wildcard such as "*.example.com", which
+// matches any subdomain of example.com.
+func wildcardMatches(wildcard, host string) bool {
+	return strings.HasPrefix(wildcard, "*.") && strings.HasSuffix(host, wildcard[1:])
+}
+
+// httpRoutePaths returns the paths matched by a route's rules. Regular expression matches are ignored.
+func httpRoutePaths(route *gateway.HTTPRoute) (paths []string, warns []error) {
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if path == "" {
+			path = "/"
+		}
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	if len(route.Spec.Rules) == 0 {
+		add("/")
+	}
+	for _, rule := range route.Spec.Rules {
+		if len(rule.Matches) == 0 {
+			add("/")
+		}
+		for _, match := range rule.Matches {
+			switch {
+			case match.Path == nil:
+				add("/")
+			case match.Path.Type == gateway.PathMatchRegularExpression:
+				warns = append(warns, fmt.Errorf("regular expression path %s can't be checked", match.Path.Value))
+			default:
+				add(match.Path.Value)
+			}
+		}
+	}
+	return paths, warns
+}
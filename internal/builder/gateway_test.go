@@ -0,0 +1,187 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/adriansr/sm-controller/internal/gateway"
+)
+
+func TestHTTPRouteChecks(t *testing.T) {
+	gateways := []*gateway.Gateway{
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "public", Namespace: "infra"},
+			Spec: gateway.GatewaySpec{
+				Listeners: []gateway.Listener{
+					{Name: "http", Port: 80, Protocol: "HTTP"},
+					{Name: "https", Port: 443, Protocol: "HTTPS", Hostname: "*.example.com"},
+					{Name: "admin", Port: 8443, Protocol: "HTTPS", Hostname: "admin.example.com"},
+					{Name: "tls", Port: 9443, Protocol: "TLS"},
+				},
+			},
+			Status: gateway.GatewayStatus{
+				Addresses: []gateway.GatewayAddress{{Type: "IPAddress", Value: "192.0.2.10"}},
+			},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "internal", Namespace: "infra"},
+			Spec: gateway.GatewaySpec{
+				Listeners: []gateway.Listener{
+					{Name: "http", Port: 80, Protocol: "HTTP"},
+					{Name: "alt", Port: 8080, Protocol: "HTTP"},
+				},
+			},
+			Status: gateway.GatewayStatus{
+				Addresses: []gateway.GatewayAddress{{Type: "IPAddress", Value: "2001:db8::10"}},
+			},
+		},
+	}
+	enabled := map[string]string{EnabledAnnotation: "true"}
+
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		spec        gateway.HTTPRouteSpec
+		targets     []string
+		jobs        []string
+		warns       int
+	}{
+		"hostnames and paths": {
+			spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "public", Namespace: "infra"}},
+				Hostnames:  []string{"shop.example.com"},
+				Rules: []gateway.HTTPRouteRule{
+					{Matches: []gateway.HTTPRouteMatch{
+						{Path: &gateway.HTTPPathMatch{Type: gateway.PathMatchPathPrefix, Value: "/cart"}},
+						{Path: &gateway.HTTPPathMatch{Type: gateway.PathMatchExact, Value: "/login"}},
+					}},
+				},
+			},
+			targets: []string{
+				"http://shop.example.com/cart",
+				"http://shop.example.com/login",
+				"https://shop.example.com/cart",
+				"https://shop.example.com/login",
+			},
+			jobs: []string{
+				"k8s_shop/web_http://shop.example.com/cart",
+				"k8s_shop/web_http://shop.example.com/login",
+				"k8s_shop/web_https://shop.example.com/cart",
+				"k8s_shop/web_https://shop.example.com/login",
+			},
+		},
+		"listener by section name": {
+			spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "public", Namespace: "infra", SectionName: "admin"}},
+			},
+			targets: []string{"https://admin.example.com:8443/"},
+			jobs:    []string{"k8s_shop/web_https://admin.example.com:8443/"},
+		},
+		"listener by port": {
+			spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "public", Namespace: "infra", Port: 80}},
+			},
+			targets: []string{"http://192.0.2.10/"},
+			jobs:    []string{"k8s_shop/web_http://192.0.2.10/"},
+		},
+		"ipv6 address": {
+			spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "internal", Namespace: "infra"}},
+			},
+			targets: []string{"http://[2001:db8::10]/", "http://[2001:db8::10]:8080/"},
+			jobs:    []string{"k8s_shop/web_http://[2001:db8::10]/", "k8s_shop/web_http://[2001:db8::10]:8080/"},
+		},
+		"host annotation": {
+			annotations: map[string]string{HostAnnotation: "www.example.com"},
+			spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "public", Namespace: "infra", SectionName: "http"}},
+			},
+			targets: []string{"http://www.example.com/"},
+			jobs:    []string{"k8s_shop/web_http://www.example.com/"},
+		},
+		"wildcard route hostname": {
+			spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "public", Namespace: "infra"}},
+				Hostnames:  []string{"*.example.com"},
+			},
+			targets: []string{"https://admin.example.com:8443/"},
+			jobs:    []string{"k8s_shop/web_https://admin.example.com:8443/"},
+			warns:   1,
+		},
+		"regular expression path": {
+			spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "public", Namespace: "infra", SectionName: "admin"}},
+				Rules: []gateway.HTTPRouteRule{
+					{Matches: []gateway.HTTPRouteMatch{
+						{Path: &gateway.HTTPPathMatch{Type: gateway.PathMatchRegularExpression, Value: "/api/v[0-9]+"}},
+					}},
+				},
+			},
+			warns: 2, // The path and no host.
+		},
+		"missing gateway": {
+			spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "public"}},
+			},
+			warns: 2, // The gateway and no host.
+		},
+		"not a gateway": {
+			spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "public", Namespace: "infra", Kind: "Service", Group: "core"}},
+			},
+			warns: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{}
+			for k, v := range enabled {
+				annotations[k] = v
+			}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			route := &gateway.HTTPRoute{
+				ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "shop", Annotations: annotations},
+				Spec:       test.spec,
+			}
+
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{
+				Gateways:   gateways,
+				HTTPRoutes: []*gateway.HTTPRoute{route},
+			})
+			require.Len(t, warns, test.warns)
+			var targets, jobs []string
+			for _, check := range checks {
+				require.NotNil(t, check.Settings.Http)
+				targets = append(targets, check.Target)
+				jobs = append(jobs, check.Job)
+			}
+			require.Equal(t, test.targets, targets)
+			require.Equal(t, test.jobs, jobs)
+		})
+	}
+}
+
+func TestListenerHostnames(t *testing.T) {
+	for name, test := range map[string]struct {
+		route    []string
+		listener string
+		expected []string
+	}{
+		"no hostnames":           {},
+		"listener only":          {listener: "a.example.com", expected: []string{"a.example.com"}},
+		"wildcard listener only": {listener: "*.example.com"},
+		"route only":             {route: []string{"a.example.com", "b.example.com"}, expected: []string{"a.example.com", "b.example.com"}},
+		"same":                   {route: []string{"a.example.com"}, listener: "a.example.com", expected: []string{"a.example.com"}},
+		"different":              {route: []string{"a.example.com"}, listener: "b.example.com"},
+		"wildcard listener":      {route: []string{"a.example.com", "a.other.com"}, listener: "*.example.com", expected: []string{"a.example.com"}},
+		"wildcard route":         {route: []string{"*.example.com"}, listener: "a.example.com", expected: []string{"a.example.com"}},
+		"both wildcards":         {route: []string{"*.example.com"}, listener: "*.example.com"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, test.expected, listenerHostnames(test.route, test.listener))
+		})
+	}
+}
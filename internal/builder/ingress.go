@@ -0,0 +1,204 @@
+package builder
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+// httpEndpoint is a single host and path combination taken from an Ingress rule or HTTPRoute.
+type httpEndpoint struct {
+	Scheme string
+	Host   string
+	// Port is only set when it's not the default port for the scheme.
+	Port int32
+	Path string
+}
+
+// urlHost returns the host part of a URL, with IPv6 addresses in brackets. The port is left out when
+// it's zero.
+func urlHost(host string, port int32) string {
+	if port != 0 {
+		return net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+func (e httpEndpoint) URL() string {
+	return fmt.Sprintf("%s://%s%s", e.Scheme, urlHost(e.Host, e.Port), e.Path)
+}
+
+func (b *Builder) ingressToChecks(ing *networkingV1.Ingress, ns *coreV1.Namespace, secrets secretStore, configMaps configMapStore) (checks []*sm.Check, warns []error, err error) {
+	opts, warns, err := b.prepareOptions(ing, ns, secrets, configMaps)
+	if err != nil || !opts.Enabled {
+		return nil, warns, err
+	}
+	if err := opts.DNS.validate(); err != nil {
+		return nil, warns, err
+	}
+
+	endpoints, hostWarns := httpEndpoints(ing, opts.Host)
+	warns = append(warns, hostWarns...)
+	if len(endpoints) == 0 {
+		return nil, append(warns, &Skipped{Reason: SkipNoHost}), nil
+	}
+	if opts.DNS.Enabled {
+		lbAddresses := ingressLoadBalancerAddresses(ing)
+		seen := make(map[string]bool)
+		for _, endpoint := range endpoints {
+			if !seen[endpoint.Host] {
+				seen[endpoint.Host] = true
+				check, err := opts.dnsCheckForHost(ing, endpoint.Host, lbAddresses)
+				if err != nil {
+					return nil, warns, err
+				}
+				checks = append(checks, check)
+			}
+		}
+	}
+	if opts.K6.enabled() {
+		seen := make(map[string]bool)
+		for _, endpoint := range endpoints {
+			if !seen[endpoint.Host] {
+				seen[endpoint.Host] = true
+				check, err := opts.k6CheckForHost(ing, endpoint.Scheme, endpoint.Host)
+				if err != nil {
+					return nil, warns, err
+				}
+				checks = append(checks, check)
+			}
+		}
+	}
+	for _, endpoint := range endpoints {
+		endpointOpts := opts.forFamily(opts.familiesFor(nil, endpoint.Host)[0])
+		check, err := endpointOpts.checkForHTTPEndpoint(ing, endpoint)
+		if err != nil {
+			return nil, warns, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, warns, nil
+}
+
+// ingressLoadBalancerAddresses returns the IPs and hostnames of the load balancers in the Ingress status.
+func ingressLoadBalancerAddresses(ing *networkingV1.Ingress) (addresses []string) {
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			addresses = append(addresses, lb.IP)
+		}
+		if lb.Hostname != "" {
+			addresses = append(addresses, lb.Hostname)
+		}
+	}
+	return addresses
+}
+
+// httpEndpoints returns the endpoints defined by the rules in an Ingress. Rules without a host use
+// defaultHost instead, or are ignored if it's empty. Wildcard hosts are ignored, as they can't be checked.
+func httpEndpoints(ing *networkingV1.Ingress, defaultHost string) (endpoints []httpEndpoint, warns []error) {
+	tlsHosts := make(map[string]bool)
+	for _, tls := range ing.Spec.TLS {
+		for _, host := range tls.Hosts {
+			tlsHosts[host] = true
+		}
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = defaultHost
+		}
+		if host == "" {
+			continue
+		}
+		if strings.HasPrefix(host, "*") {
+			warns = append(warns, fmt.Errorf("wildcard hostname %s can't be checked", host))
+			continue
+		}
+		scheme := "http"
+		if tlsHosts[host] {
+			scheme = "https"
+		}
+		if rule.HTTP == nil || len(rule.HTTP.Paths) == 0 {
+			endpoints = append(endpoints, httpEndpoint{
+				Scheme: scheme,
+				Host:   host,
+				Path:   "/",
+			})
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			p := path.Path
+			if p == "" {
+				p = "/"
+			}
+			endpoints = append(endpoints, httpEndpoint{
+				Scheme: scheme,
+				Host:   host,
+				Path:   p,
+			})
+		}
+	}
+	return endpoints, warns
+}
+
+func (opts *CheckOptions) checkForHTTPEndpoint(obj metaV1.Object, endpoint httpEndpoint) (*sm.Check, error) {
+	check := opts.newCheck(endpoint.URL())
+
+	data := JobData{
+		Host:     endpoint.Host,
+		Protocol: strings.ToUpper(endpoint.Scheme),
+		Path:     endpoint.Path,
+		URL:      endpoint.URL(),
+	}
+	if err := opts.nameCheck(check, obj, data); err != nil {
+		return nil, err
+	}
+	if opts.Target != "" {
+		check.Target = opts.Target
+	}
+
+	check.Settings.Http = opts.httpSettings()
+
+	return check, nil
+}
+
+// httpSettings returns the settings for an HTTP check.
+func (opts *CheckOptions) httpSettings() *sm.HttpSettings {
+	settings := opts.HTTP.settings(opts.Family)
+	settings.TlsConfig = opts.TLS.config()
+	return settings
+}
+
+func (opts *HTTPOptions) settings(family ipFamily) *sm.HttpSettings {
+	return &sm.HttpSettings{
+		IpVersion:                  family.resolve(opts.IpVersion),
+		Method:                     opts.Method,
+		Headers:                    opts.Headers,
+		Body:                       opts.Body,
+		NoFollowRedirects:          opts.NoFollowRedirects,
+		ValidStatusCodes:           opts.ValidStatusCodes,
+		ValidHTTPVersions:          opts.ValidHTTPVersions,
+		CacheBustingQueryParamName: opts.CacheBustingParam,
+
+		FailIfBodyMatchesRegexp:      opts.FailIfBodyMatchesRegexp,
+		FailIfBodyNotMatchesRegexp:   opts.FailIfBodyNotMatchesRegexp,
+		FailIfHeaderMatchesRegexp:    opts.FailIfHeaderMatchesRegexp,
+		FailIfHeaderNotMatchesRegexp: opts.FailIfHeaderNotMatchesRegexp,
+		FailIfSSL:                    opts.FailIfSSL,
+		FailIfNotSSL:                 opts.FailIfNotSSL,
+
+		BasicAuth:   opts.basicAuth,
+		BearerToken: opts.bearerToken,
+	}
+}
@@ -0,0 +1,63 @@
+package builder
+
+import (
+	"net"
+
+	coreV1 "k8s.io/api/core/v1"
+
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+type ipFamily struct {
+	Version sm.IpVersion
+	// Name differentiates the checks created for each family of a dual-stack target.
+	Name string
+}
+
+// resolve returns the IP version to use for a check, given an optional per-check-type override.
+func (f ipFamily) resolve(override *sm.IpVersion) sm.IpVersion {
+	if override != nil {
+		return *override
+	}
+	return f.Version
+}
+
+// familiesFor returns the IP families to create checks for a host. An explicit IP version always wins,
+// then IP literals use their own family and hostnames use the Service's IP families. Hostnames for
+// dual-stack services get one family per stack if requested, otherwise any version is accepted.
+func (opts *CheckOptions) familiesFor(families []coreV1.IPFamily, host string) []ipFamily {
+	if opts.IpVersion != nil {
+		return []ipFamily{{Version: *opts.IpVersion}}
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() != nil {
+			return []ipFamily{{Version: sm.IpVersion_V4}}
+		}
+		return []ipFamily{{Version: sm.IpVersion_V6}}
+	}
+
+	switch {
+	case len(families) > 1 && opts.IpVersionPerFamily:
+		return []ipFamily{
+			{Version: sm.IpVersion_V4, Name: "IPv4"},
+			{Version: sm.IpVersion_V6, Name: "IPv6"},
+		}
+
+	case len(families) > 1:
+		return []ipFamily{{Version: sm.IpVersion_Any}}
+
+	case len(families) == 1 && families[0] == coreV1.IPv6Protocol:
+		return []ipFamily{{Version: sm.IpVersion_V6}}
+
+	default:
+		return []ipFamily{{Version: sm.IpVersion_V4}}
+	}
+}
+
+// forFamily returns a copy of the options for building checks of the given IP family.
+func (opts *CheckOptions) forFamily(family ipFamily) *CheckOptions {
+	result := *opts
+	result.Family = family
+	return &result
+}
@@ -0,0 +1,86 @@
+package builder
+
+import (
+	"fmt"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+// K6ScriptKey is the default key of the script in the ConfigMap referenced by K6ScriptConfigMapAnnotation.
+const K6ScriptKey = "script.js"
+
+type K6Options struct {
+	// ConfigMap and Key locate the script. No k6 checks are created when ConfigMap is empty.
+	ConfigMap string
+	Key       string
+
+	script []byte
+}
+
+func parseK6Options(annotations map[string]string, opts K6Options) K6Options {
+	if name := annotations[K6ScriptConfigMapAnnotation]; name != "" {
+		opts.ConfigMap = name
+	}
+	if key := annotations[K6ScriptKeyAnnotation]; key != "" {
+		opts.Key = key
+	}
+	return opts
+}
+
+// configMapStore holds the ConfigMaps available to the checks, keyed by namespace and name.
+type configMapStore map[string]*coreV1.ConfigMap
+
+func newConfigMapStore(configMaps []*coreV1.ConfigMap) configMapStore {
+	store := make(configMapStore, len(configMaps))
+	for _, cm := range configMaps {
+		store[cm.Namespace+"/"+cm.Name] = cm
+	}
+	return store
+}
+
+// enabled returns whether k6 checks must be created.
+func (opts *K6Options) enabled() bool {
+	return len(opts.script) > 0
+}
+
+// resolve reads the script from the referenced ConfigMap. When it can't be read, a warning is returned
+// and no k6 checks are created.
+func (opts *K6Options) resolve(namespace string, configMaps configMapStore) error {
+	if opts.ConfigMap == "" {
+		return nil
+	}
+	cm, found := configMaps[namespace+"/"+opts.ConfigMap]
+	if !found {
+		return fmt.Errorf("k6 script configmap %s/%s not found", namespace, opts.ConfigMap)
+	}
+	if script, found := cm.Data[opts.Key]; found {
+		opts.script = []byte(script)
+	} else {
+		opts.script = cm.BinaryData[opts.Key]
+	}
+	if len(opts.script) == 0 {
+		return fmt.Errorf("k6 script configmap %s/%s has no %s key", namespace, opts.ConfigMap, opts.Key)
+	}
+	return nil
+}
+
+func (opts *CheckOptions) k6CheckForHost(obj metaV1.Object, scheme, host string) (*sm.Check, error) {
+	check := opts.newCheck(fmt.Sprintf("%s://%s/", scheme, urlHost(host, 0)))
+
+	if err := opts.nameCheck(check, obj, JobData{Host: host, Protocol: "k6"}); err != nil {
+		return nil, err
+	}
+
+	check.Settings.K6 = opts.k6Settings()
+
+	return check, nil
+}
+
+func (opts *CheckOptions) k6Settings() *sm.K6Settings {
+	return &sm.K6Settings{
+		Script: opts.K6.script,
+	}
+}
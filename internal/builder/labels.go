@@ -0,0 +1,129 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+// maxUserLabels leaves room for the label used to mark checks as managed by the controller.
+const maxUserLabels = sm.MaxCheckLabels - 1
+
+var errTooManyLabels = errors.New("too many check labels")
+
+// Label sources, from lowest to highest precedence.
+const (
+	labelFromController = iota
+	labelFromNamespace
+	labelFromObject
+	labelFromAnnotation
+)
+
+// parseLabels parses a "name=value,name2=value2" annotation. Malformed elements are kept
+// with an empty value so that they're reported by checkLabels.
+func parseLabels(value string) (labels []sm.Label) {
+	for _, elem := range splitList(value) {
+		name, val, _ := strings.Cut(elem, "=")
+		labels = append(labels, sm.Label{
+			Name:  strings.TrimSpace(name),
+			Value: strings.TrimSpace(val),
+		})
+	}
+	return labels
+}
+
+// ParseLabels parses a "name=value,name2=value2" list of check labels, failing on invalid labels.
+func ParseLabels(value string) ([]sm.Label, error) {
+	labels := parseLabels(value)
+	for _, label := range labels {
+		if err := label.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid label %q=%q: %w", label.Name, label.Value, err)
+		}
+	}
+	return labels, nil
+}
+
+// sanitizeLabelName converts a Kubernetes label key into a valid check label name.
+func sanitizeLabelName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// checkLabels returns the labels for the checks of an object. These are the controller labels, the
+// allowlisted namespace and object labels, and the labels from the annotation, in increasing order of
+// precedence. Invalid labels and labels above the per-check limit are dropped and reported.
+func (opt *Options) checkLabels(annotated []sm.Label, obj metaV1.Object, ns *coreV1.Namespace) (labels []sm.Label, warns []error) {
+	type sourced struct {
+		sm.Label
+		source int
+	}
+
+	merged := make(map[string]sourced)
+	add := func(label sm.Label, source int) {
+		if label.Name == sm.ManagedLabel {
+			warns = append(warns, fmt.Errorf("label %s is reserved", label.Name))
+			return
+		}
+		if err := label.Validate(); err != nil {
+			warns = append(warns, fmt.Errorf("invalid label %q=%q: %w", label.Name, label.Value, err))
+			return
+		}
+		merged[label.Name] = sourced{Label: label, source: source}
+	}
+	copyLabels := func(keys []string, from map[string]string, source int) {
+		for _, key := range keys {
+			if value := from[key]; value != "" {
+				add(sm.Label{Name: sanitizeLabelName(key), Value: value}, source)
+			}
+		}
+	}
+
+	for _, label := range opt.Labels {
+		add(label, labelFromController)
+	}
+	if ns != nil {
+		copyLabels(opt.CopyNamespaceLabels, ns.Labels, labelFromNamespace)
+	}
+	copyLabels(opt.CopyLabels, obj.GetLabels(), labelFromObject)
+	for _, label := range annotated {
+		add(label, labelFromAnnotation)
+	}
+
+	all := make([]sourced, 0, len(merged))
+	for _, label := range merged {
+		all = append(all, label)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].source != all[j].source {
+			return all[i].source > all[j].source
+		}
+		return all[i].Name < all[j].Name
+	})
+	if len(all) > maxUserLabels {
+		var dropped []string
+		for _, label := range all[maxUserLabels:] {
+			dropped = append(dropped, label.Name)
+		}
+		warns = append(warns, fmt.Errorf("%w: limit is %d, dropped %s",
+			errTooManyLabels, maxUserLabels, strings.Join(dropped, ",")))
+		all = all[:maxUserLabels]
+	}
+
+	for _, label := range all {
+		labels = append(labels, label.Label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels, warns
+}
@@ -0,0 +1,71 @@
+package builder
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var errObjectOnly = errors.New("can't be set on a namespace")
+
+// namespaceAnnotations returns the annotations of a namespace that are used as defaults for the
+// objects in it. Annotations that only apply to a single object, other than the enabled annotation,
+// are left out and reported.
+func namespaceAnnotations(ns *coreV1.Namespace) (map[string]string, []error) {
+	if ns == nil {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(ns.Annotations))
+	for key := range ns.Annotations {
+		if strings.HasPrefix(key, AnnotationsPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	annotations := make(map[string]string)
+	var errs []error
+	for _, key := range keys {
+		value := ns.Annotations[key]
+		if objectOnlyAnnotations[key] && key != EnabledAnnotation {
+			errs = append(errs, &InvalidAnnotation{Annotation: key, Value: value, Err: errObjectOnly})
+			continue
+		}
+		annotations[key] = value
+	}
+	return annotations, errs
+}
+
+// isAnnotated returns whether an object or its namespace have the enabled annotation. Other objects
+// are ignored without reporting them as skipped.
+func isAnnotated(obj metaV1.Object, ns *coreV1.Namespace) bool {
+	if _, found := obj.GetAnnotations()[EnabledAnnotation]; found {
+		return true
+	}
+	if ns != nil {
+		_, found := ns.Annotations[EnabledAnnotation]
+		return found
+	}
+	return false
+}
+
+// mergeAnnotations merges annotations from lowest to highest precedence. Check labels are merged
+// so that each layer only overrides the labels it sets.
+func mergeAnnotations(layers ...map[string]string) map[string]string {
+	if len(layers) == 1 {
+		return layers[0]
+	}
+	merged := make(map[string]string)
+	for _, layer := range layers {
+		for key, value := range layer {
+			if key == LabelsAnnotation && merged[key] != "" {
+				value = merged[key] + "," + value
+			}
+			merged[key] = value
+		}
+	}
+	return merged
+}
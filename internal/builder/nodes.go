@@ -0,0 +1,65 @@
+package builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/adriansr/sm-controller/internal/helpers/hrw"
+)
+
+type NodeOptions struct {
+	// Count is the number of nodes to check a NodePort service through. Zero means all nodes.
+	Count       int
+	Selector    string
+	AddressType coreV1.NodeAddressType
+}
+
+func parseNodeOptions(annotations map[string]string, opts NodeOptions) NodeOptions {
+	if count := annotations[NodeCountAnnotation]; strings.EqualFold(count, "all") {
+		opts.Count = 0
+	} else if n, err := strconv.ParseUint(count, 10, 16); err == nil && n > 0 {
+		opts.Count = int(n)
+	}
+	if selector, found := annotations[NodeSelectorAnnotation]; found {
+		opts.Selector = selector
+	}
+	if addrType := annotations[NodeAddressTypeAnnotation]; addrType != "" {
+		opts.AddressType = coreV1.NodeAddressType(addrType)
+	}
+	return opts
+}
+
+// selectAddresses returns the addresses of the nodes used to check a NodePort service.
+// Nodes are chosen using rendezvous hashing so that the same service keeps using the same nodes
+// and different services are spread across the cluster.
+func (opts *NodeOptions) selectAddresses(svc *coreV1.Service, nodes []*coreV1.Node) ([]string, error) {
+	selector, err := labels.Parse(opts.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node selector in %s: %w", NodeSelectorAnnotation, err)
+	}
+
+	var candidates []string
+	for _, node := range nodes {
+		if !selector.Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		if addr := nodeAddress(node, opts.AddressType); addr != "" {
+			candidates = append(candidates, addr)
+		}
+	}
+
+	return hrw.Select(svc.Namespace+"/"+svc.Name, candidates, opts.Count), nil
+}
+
+func nodeAddress(node *coreV1.Node, addrType coreV1.NodeAddressType) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == addrType {
+			return addr.Address
+		}
+	}
+	return ""
+}
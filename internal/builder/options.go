@@ -1,8 +1,12 @@
 package builder
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
 
 	sm "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
 )
@@ -13,21 +17,153 @@ const (
 	NameAnnotation      = AnnotationsPrefix + "name"
 	FrequencyAnnotation = AnnotationsPrefix + "frequency"
 	TimeoutAnnotation   = AnnotationsPrefix + "timeout"
-	ProbesAnnotation    = AnnotationsPrefix + "probes"
-	HostAnnotation      = AnnotationsPrefix + "host" // TODO
+	ProbesAnnotation    = AnnotationsPrefix + "probes" // Probe names or selectors, see sm.ProbeSet.Select.
+	HostAnnotation      = AnnotationsPrefix + "host"   // TODO
+	LabelsAnnotation    = AnnotationsPrefix + "labels" // Check labels as "name=value,name2=value2".
+
+	// ProbeCountAnnotation selects a subset of the probes, or of all the probes when none are set.
+	ProbeCountAnnotation = AnnotationsPrefix + "probe-count"
+
+	// Alerting. The sensitivity is one of none, low, medium or high.
+	AlertSensitivityAnnotation = AnnotationsPrefix + "alert-sensitivity"
+	BasicMetricsOnlyAnnotation = AnnotationsPrefix + "basic-metrics-only"
+
+	// HTTP check settings.
+	HTTPMethodAnnotation            = AnnotationsPrefix + "http-method"
+	HTTPHeadersAnnotation           = AnnotationsPrefix + "http-headers" // One "Name: value" header per line.
+	HTTPBodyAnnotation              = AnnotationsPrefix + "http-body"
+	HTTPValidStatusCodesAnnotation  = AnnotationsPrefix + "http-valid-status-codes"
+	HTTPValidVersionsAnnotation     = AnnotationsPrefix + "http-valid-http-versions"
+	HTTPFollowRedirectsAnnotation   = AnnotationsPrefix + "http-follow-redirects"
+	HTTPNoFollowRedirectsAnnotation = AnnotationsPrefix + "http-no-follow-redirects"
+	HTTPCacheBustingAnnotation      = AnnotationsPrefix + "http-cache-busting-query-param"
+	HTTPIPVersionAnnotation         = AnnotationsPrefix + "http-ip-version"
+
+	// HTTP authentication, from a Secret in the object's namespace. Basic auth Secrets use the
+	// username and password keys, bearer token Secrets use the token key.
+	BasicAuthSecretAnnotation   = AnnotationsPrefix + "basic-auth-secret"
+	BearerTokenSecretAnnotation = AnnotationsPrefix + "bearer-token-secret"
+
+	// TLS settings for HTTP and TCP checks. The Secret, in the object's namespace, can hold a CA
+	// bundle in the ca.crt key and a client certificate in the tls.crt and tls.key keys. TCP checks
+	// only use TLS when tcp-tls is set.
+	TLSSecretAnnotation             = AnnotationsPrefix + "tls-secret"
+	TLSServerNameAnnotation         = AnnotationsPrefix + "tls-server-name"
+	TLSInsecureSkipVerifyAnnotation = AnnotationsPrefix + "tls-insecure-skip-verify"
+	TCPTLSAnnotation                = AnnotationsPrefix + "tcp-tls"
+
+	// HTTP response assertions. Regexp lists take one expression per line, header matches
+	// take one "Name: regexp" entry per line.
+	HTTPFailIfBodyMatchesAnnotation      = AnnotationsPrefix + "http-fail-if-body-matches-regexp"
+	HTTPFailIfBodyNotMatchesAnnotation   = AnnotationsPrefix + "http-fail-if-body-not-matches-regexp"
+	HTTPFailIfHeaderMatchesAnnotation    = AnnotationsPrefix + "http-fail-if-header-matches-regexp"
+	HTTPFailIfHeaderNotMatchesAnnotation = AnnotationsPrefix + "http-fail-if-header-not-matches-regexp"
+	HTTPFailIfSSLAnnotation              = AnnotationsPrefix + "http-fail-if-ssl"
+	HTTPFailIfNotSSLAnnotation           = AnnotationsPrefix + "http-fail-if-not-ssl"
+
+	// Ping checks. The ping annotation accepts a boolean to create ping checks in addition
+	// to TCP checks, or "only" to create ping checks instead.
+	PingAnnotation             = AnnotationsPrefix + "ping"
+	PingPacketCountAnnotation  = AnnotationsPrefix + "ping-packet-count"
+	PingPayloadSizeAnnotation  = AnnotationsPrefix + "ping-payload-size"
+	PingDontFragmentAnnotation = AnnotationsPrefix + "ping-dont-fragment"
+	PingIPVersionAnnotation    = AnnotationsPrefix + "ping-ip-version"
+
+	// DNS checks. ExternalName services always get a DNS check, Ingresses need the dns annotation
+	// set to get one check per host. Expected answers take one regexp per line.
+	DNSAnnotation                   = AnnotationsPrefix + "dns"
+	DNSRecordTypeAnnotation         = AnnotationsPrefix + "dns-record-type"
+	DNSServerAnnotation             = AnnotationsPrefix + "dns-server"
+	DNSPortAnnotation               = AnnotationsPrefix + "dns-port"
+	DNSProtocolAnnotation           = AnnotationsPrefix + "dns-protocol"
+	DNSIPVersionAnnotation          = AnnotationsPrefix + "dns-ip-version"
+	DNSExpectedAnswersAnnotation    = AnnotationsPrefix + "dns-expected-answers"
+	DNSExpectLoadBalancerAnnotation = AnnotationsPrefix + "dns-expect-load-balancer"
+
+	// Traceroute checks, created per host in addition to the TCP checks.
+	TracerouteAnnotation               = AnnotationsPrefix + "traceroute"
+	TracerouteMaxHopsAnnotation        = AnnotationsPrefix + "traceroute-max-hops"
+	TracerouteMaxUnknownHopsAnnotation = AnnotationsPrefix + "traceroute-max-unknown-hops"
+	TracerouteHopTimeoutAnnotation     = AnnotationsPrefix + "traceroute-hop-timeout"
+	TraceroutePTRLookupAnnotation      = AnnotationsPrefix + "traceroute-ptr-lookup"
+
+	// k6 scripted checks, created per host with the script in a ConfigMap key in the object's
+	// namespace. The key defaults to K6ScriptKey.
+	K6ScriptConfigMapAnnotation = AnnotationsPrefix + "k6-script-configmap"
+	K6ScriptKeyAnnotation       = AnnotationsPrefix + "k6-script-key"
+
+	// Node selection for NodePort services. The node count is either a number or "all", the
+	// selector uses the Kubernetes label selector syntax.
+	NodeCountAnnotation       = AnnotationsPrefix + "node-count"
+	NodeSelectorAnnotation    = AnnotationsPrefix + "node-selector"
+	NodeAddressTypeAnnotation = AnnotationsPrefix + "node-address-type"
+
+	// IP version selection. The IP version is chosen from the target address or the Service's
+	// IP families unless set explicitly. Dual-stack targets can get a check for each family.
+	IPVersionAnnotation          = AnnotationsPrefix + "ip-version"
+	IPVersionPerFamilyAnnotation = AnnotationsPrefix + "ip-version-per-family"
+
+	// Port selection, as a list of port names or numbers.
+	PortsAnnotation = AnnotationsPrefix + "ports"
+
+	// Per-port overrides use PortAnnotationsPrefix + "<port name or number>." + setting.
+	PortAnnotationsPrefix = AnnotationsPrefix + "port."
+	PortFrequencySetting  = "frequency"
+	PortTimeoutSetting    = "timeout"
+	PortTypeSetting       = "type"
+	PortPathSetting       = "path"
 )
 
 var defaultCheckOptions = CheckOptions{
 	Frequency: 60000,
 	Timeout:   3000,
 	Probes:    []string{"Atlanta", "NewYork", "Paris", "Singapore"},
+	HTTP: HTTPOptions{
+		Method: sm.HttpMethod_GET,
+	},
+	Ping: PingOptions{
+		PacketCount: 1,
+	},
+	DNS: DNSOptions{
+		RecordType: sm.DnsRecordType_A,
+		Server:     "dns.google",
+		Port:       53,
+		Protocol:   sm.DnsProtocol_UDP,
+		IpVersion:  sm.IpVersion_V4,
+	},
+	Traceroute: TracerouteOptions{
+		// The API only accepts these values for traceroute checks.
+		Frequency:      120000,
+		Timeout:        30000,
+		MaxHops:        64,
+		MaxUnknownHops: 15,
+		PtrLookup:      true,
+	},
+	K6: K6Options{
+		Key: K6ScriptKey,
+	},
+	Nodes: NodeOptions{
+		Count:       1,
+		AddressType: "ExternalIP",
+	},
 }
 
 type Options struct {
-	// TODO: Config options for how the checks are built
+	// ClusterName identifies the cluster in job names, DefaultClusterName is used when empty.
 	ClusterName string
-	Labels      []sm.Label
-	defaults    CheckOptions
+	// JobNameTemplate and TargetTemplate generate the job name and target of the checks, see
+	// JobData for the available fields. The default templates are used when nil.
+	JobNameTemplate *template.Template
+	TargetTemplate  *template.Template
+	Labels          []sm.Label
+	// CopyLabels and CopyNamespaceLabels are the keys of the labels copied to the checks
+	// from the monitored objects and their namespaces.
+	CopyLabels          []string
+	CopyNamespaceLabels []string
+	// AlertSensitivity and BasicMetricsOnly are the defaults for checks without annotations.
+	AlertSensitivity string
+	BasicMetricsOnly bool
+	defaults         CheckOptions
 }
 
 func NewOptions() Options {
@@ -44,30 +180,299 @@ type CheckOptions struct {
 	Timeout   int64
 	Labels    []sm.Label
 	Probes    []string
+	// ProbeCount is the number of probes chosen from those matched by Probes, or zero for all.
+	ProbeCount int
+
+	AlertSensitivity string
+	BasicMetricsOnly bool
 
 	// These are modifiers:
 	Host   string
 	Target string
+
+	// Settings for HTTP checks:
+	HTTP HTTPOptions
+
+	// TLS settings for HTTP and TCP checks:
+	TLS TLSOptions
+
+	// Settings for ping checks:
+	Ping PingOptions
+
+	// Settings for DNS checks:
+	DNS DNSOptions
+
+	// Settings for traceroute checks:
+	Traceroute TracerouteOptions
+
+	// Settings for k6 checks:
+	K6 K6Options
+
+	// Node selection for NodePort services:
+	Nodes NodeOptions
+
+	// Port selection and per-port overrides:
+	Ports         []string
+	PortOverrides map[string]PortOptions
+	// Port holds the effective settings for the port being built, see forPort.
+	Port PortOptions
+
+	// IP version selection. IpVersion is nil when it must be chosen for each target.
+	IpVersion          *sm.IpVersion
+	IpVersionPerFamily bool
+	// Family holds the effective IP family for the check being built, see forFamily.
+	Family ipFamily
+
+	templates checkTemplates
+}
+
+type HTTPOptions struct {
+	Method            sm.HttpMethod
+	Headers           []string
+	Body              string
+	ValidStatusCodes  []int32
+	ValidHTTPVersions []string
+	NoFollowRedirects bool
+	CacheBustingParam string
+	// IpVersion overrides the IP version chosen for the target when set.
+	IpVersion *sm.IpVersion
+
+	FailIfBodyMatchesRegexp      []string
+	FailIfBodyNotMatchesRegexp   []string
+	FailIfHeaderMatchesRegexp    []sm.HeaderMatch
+	FailIfHeaderNotMatchesRegexp []sm.HeaderMatch
+	FailIfSSL                    bool
+	FailIfNotSSL                 bool
+
+	// Names of the Secrets holding the credentials, see resolveAuth.
+	BasicAuthSecret   string
+	BearerTokenSecret string
+	basicAuth         *sm.BasicAuth
+	bearerToken       string
 }
 
-func (opt *Options) NewCheckOptions(annotations map[string]string) (opts CheckOptions) {
+// NewCheckOptions returns the options for the checks of an object. Annotations are given from
+// lowest to highest precedence, such as the namespace's and then the object's annotations.
+func (opt *Options) NewCheckOptions(layers ...map[string]string) (opts CheckOptions) {
+	annotations := mergeAnnotations(layers...)
 	opts = opt.defaults
+	if opt.AlertSensitivity != "" {
+		opts.AlertSensitivity = opt.AlertSensitivity
+	}
+	opts.BasicMetricsOnly = opt.BasicMetricsOnly
+	opts.templates = opt.checkTemplates()
 	if enabled, err := strconv.ParseBool(annotations[EnabledAnnotation]); err == nil {
 		opts.Enabled = enabled
 	}
 	if name := annotations[NameAnnotation]; name != "" {
 		opts.JobName = name
 	}
-	if freq, err := strconv.ParseUint(annotations[FrequencyAnnotation], 10, 32); err == nil {
-		opts.Frequency = int64(freq)
-	}
-	if timeout, err := strconv.ParseUint(annotations[TimeoutAnnotation], 10, 32); err == nil {
-		opts.Timeout = int64(timeout)
+	if freq, err := parseFrequency(annotations[FrequencyAnnotation]); err == nil {
+		opts.Frequency = freq
 	}
-	if probes := strings.Split(annotations[ProbesAnnotation], ","); len(probes) > 0 && len(probes[0]) > 0 {
-		opts.Probes = probes
+	if timeout, err := parseTimeout(annotations[TimeoutAnnotation]); err == nil {
+		opts.Timeout = timeout
 	}
+	opts.Probes, opts.ProbeCount = parseProbes(annotations, opts.Probes, opts.ProbeCount)
 	opts.Host = annotations[HostAnnotation]
+	if labels := parseLabels(annotations[LabelsAnnotation]); len(labels) > 0 {
+		opts.Labels = labels
+	}
+	if sensitivity, err := ParseAlertSensitivity(annotations[AlertSensitivityAnnotation]); err == nil && sensitivity != "" {
+		opts.AlertSensitivity = sensitivity
+	}
+	if basicOnly, err := strconv.ParseBool(annotations[BasicMetricsOnlyAnnotation]); err == nil {
+		opts.BasicMetricsOnly = basicOnly
+	}
+	opts.HTTP = parseHTTPOptions(annotations, opts.HTTP)
+	opts.TLS = parseTLSOptions(annotations, opts.TLS)
+	opts.Ping = parsePingOptions(annotations, opts.Ping)
+	opts.DNS = parseDNSOptions(annotations, opts.DNS)
+	opts.Traceroute = parseTracerouteOptions(annotations, opts.Traceroute)
+	opts.K6 = parseK6Options(annotations, opts.K6)
+	opts.Nodes = parseNodeOptions(annotations, opts.Nodes)
+	if ports := splitList(annotations[PortsAnnotation]); len(ports) > 0 {
+		opts.Ports = ports
+	}
+	opts.PortOverrides = parsePortOverrides(annotations, opts.PortOverrides)
+	if version, found := parseIpVersion(annotations[IPVersionAnnotation]); found {
+		opts.IpVersion = &version
+	}
+	if perFamily, err := strconv.ParseBool(annotations[IPVersionPerFamilyAnnotation]); err == nil {
+		opts.IpVersionPerFamily = perFamily
+	}
+
+	return opts
+}
 
+func parseHTTPOptions(annotations map[string]string, opts HTTPOptions) HTTPOptions {
+	if method, found := sm.HttpMethod_value[strings.ToUpper(annotations[HTTPMethodAnnotation])]; found {
+		opts.Method = sm.HttpMethod(method)
+	}
+	if headers := splitLines(annotations[HTTPHeadersAnnotation]); len(headers) > 0 {
+		opts.Headers = headers
+	}
+	if body, found := annotations[HTTPBodyAnnotation]; found {
+		opts.Body = body
+	}
+	var codes []int32
+	for _, code := range splitList(annotations[HTTPValidStatusCodesAnnotation]) {
+		if value, err := strconv.ParseUint(code, 10, 16); err == nil && value >= 100 && value <= 599 {
+			codes = append(codes, int32(value))
+		}
+	}
+	if len(codes) > 0 {
+		opts.ValidStatusCodes = codes
+	}
+	if versions := splitList(annotations[HTTPValidVersionsAnnotation]); len(versions) > 0 {
+		opts.ValidHTTPVersions = versions
+	}
+	if follow, err := strconv.ParseBool(annotations[HTTPFollowRedirectsAnnotation]); err == nil {
+		opts.NoFollowRedirects = !follow
+	}
+	if noFollow, err := strconv.ParseBool(annotations[HTTPNoFollowRedirectsAnnotation]); err == nil {
+		opts.NoFollowRedirects = noFollow
+	}
+	if param := annotations[HTTPCacheBustingAnnotation]; param != "" {
+		opts.CacheBustingParam = param
+	}
+	if version, found := parseIpVersion(annotations[HTTPIPVersionAnnotation]); found {
+		opts.IpVersion = &version
+	}
+	if exprs := splitLines(annotations[HTTPFailIfBodyMatchesAnnotation]); len(exprs) > 0 {
+		opts.FailIfBodyMatchesRegexp = exprs
+	}
+	if exprs := splitLines(annotations[HTTPFailIfBodyNotMatchesAnnotation]); len(exprs) > 0 {
+		opts.FailIfBodyNotMatchesRegexp = exprs
+	}
+	if matches := parseHeaderMatches(annotations[HTTPFailIfHeaderMatchesAnnotation]); len(matches) > 0 {
+		opts.FailIfHeaderMatchesRegexp = matches
+	}
+	if matches := parseHeaderMatches(annotations[HTTPFailIfHeaderNotMatchesAnnotation]); len(matches) > 0 {
+		opts.FailIfHeaderNotMatchesRegexp = matches
+	}
+	if failIfSSL, err := strconv.ParseBool(annotations[HTTPFailIfSSLAnnotation]); err == nil {
+		opts.FailIfSSL = failIfSSL
+	}
+	if failIfNotSSL, err := strconv.ParseBool(annotations[HTTPFailIfNotSSLAnnotation]); err == nil {
+		opts.FailIfNotSSL = failIfNotSSL
+	}
+	if name := annotations[BasicAuthSecretAnnotation]; name != "" {
+		opts.BasicAuthSecret = name
+	}
+	if name := annotations[BearerTokenSecretAnnotation]; name != "" {
+		opts.BearerTokenSecret = name
+	}
 	return opts
 }
+
+// validate checks that the assertions in the HTTP options are usable.
+func (opts *HTTPOptions) validate() error {
+	if opts.FailIfSSL && opts.FailIfNotSSL {
+		return errors.New("both fail-if-ssl and fail-if-not-ssl are set")
+	}
+	for _, expr := range opts.FailIfBodyMatchesRegexp {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid body regexp in %s: %w", HTTPFailIfBodyMatchesAnnotation, err)
+		}
+	}
+	for _, expr := range opts.FailIfBodyNotMatchesRegexp {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid body regexp in %s: %w", HTTPFailIfBodyNotMatchesAnnotation, err)
+		}
+	}
+	for _, match := range opts.FailIfHeaderMatchesRegexp {
+		if err := validateHeaderMatch(match); err != nil {
+			return fmt.Errorf("invalid header match in %s: %w", HTTPFailIfHeaderMatchesAnnotation, err)
+		}
+	}
+	for _, match := range opts.FailIfHeaderNotMatchesRegexp {
+		if err := validateHeaderMatch(match); err != nil {
+			return fmt.Errorf("invalid header match in %s: %w", HTTPFailIfHeaderNotMatchesAnnotation, err)
+		}
+	}
+	return nil
+}
+
+func validateHeaderMatch(match sm.HeaderMatch) error {
+	if match.Header == "" {
+		return errors.New("missing header name")
+	}
+	if _, err := regexp.Compile(match.Regexp); err != nil {
+		return err
+	}
+	return nil
+}
+
+// alertSensitivities are the sensitivity levels accepted by the API.
+var alertSensitivities = []string{"none", "low", "medium", "high"}
+
+// ParseAlertSensitivity returns the normalized alert sensitivity level for value.
+// An empty value is returned as is.
+func ParseAlertSensitivity(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return "", nil
+	}
+	for _, level := range alertSensitivities {
+		if value == level {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("invalid alert sensitivity %q, must be one of %s", value, strings.Join(alertSensitivities, ", "))
+}
+
+// splitLines splits a multi-line annotation value, discarding empty lines.
+func splitLines(value string) (lines []string) {
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseHeaderMatches parses one "Name: regexp" header match per line. Lines without a colon
+// are returned with an empty header name so that they're reported by validate.
+func parseHeaderMatches(value string) (matches []sm.HeaderMatch) {
+	for _, line := range splitLines(value) {
+		name, expr, found := strings.Cut(line, ":")
+		if !found {
+			name, expr = "", line
+		}
+		matches = append(matches, sm.HeaderMatch{
+			Header: strings.TrimSpace(name),
+			Regexp: strings.TrimSpace(expr),
+		})
+	}
+	return matches
+}
+
+// splitList splits a comma-separated annotation value, discarding empty elements.
+func splitList(value string) (list []string) {
+	for _, elem := range strings.Split(value, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
+
+func parseIpVersion(value string) (sm.IpVersion, bool) {
+	for name, version := range sm.IpVersion_value {
+		if strings.EqualFold(name, value) {
+			return sm.IpVersion(version), true
+		}
+	}
+	return sm.IpVersion_Any, false
+}
+
+func parseDnsRecordType(value string) (sm.DnsRecordType, bool) {
+	recordType, found := sm.DnsRecordType_value[strings.ToUpper(value)]
+	return sm.DnsRecordType(recordType), found
+}
+
+func parseDnsProtocol(value string) (sm.DnsProtocol, bool) {
+	protocol, found := sm.DnsProtocol_value[strings.ToUpper(value)]
+	return sm.DnsProtocol(protocol), found
+}
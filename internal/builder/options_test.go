@@ -0,0 +1,202 @@
+package builder
+
+import (
+	"testing"
+
+	sm "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPOptions(t *testing.T) {
+	v6 := sm.IpVersion_V6
+
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		expected    HTTPOptions
+	}{
+		"defaults": {
+			expected: defaultCheckOptions.HTTP,
+		},
+		"all set": {
+			annotations: map[string]string{
+				HTTPMethodAnnotation:           "post",
+				HTTPHeadersAnnotation:          "Content-Type: application/json\n\nX-Token: abc, def\n",
+				HTTPBodyAnnotation:             `{"ping": true}`,
+				HTTPValidStatusCodesAnnotation: "200, 201,",
+				HTTPValidVersionsAnnotation:    "HTTP/1.1,HTTP/2.0",
+				HTTPFollowRedirectsAnnotation:  "false",
+				HTTPCacheBustingAnnotation:     "cb",
+				HTTPIPVersionAnnotation:        "v6",
+			},
+			expected: HTTPOptions{
+				Method:            sm.HttpMethod_POST,
+				Headers:           []string{"Content-Type: application/json", "X-Token: abc, def"},
+				Body:              `{"ping": true}`,
+				ValidStatusCodes:  []int32{200, 201},
+				ValidHTTPVersions: []string{"HTTP/1.1", "HTTP/2.0"},
+				NoFollowRedirects: true,
+				CacheBustingParam: "cb",
+				IpVersion:         &v6,
+			},
+		},
+		"assertions": {
+			annotations: map[string]string{
+				HTTPFailIfBodyMatchesAnnotation:      "error\nfailed, retry",
+				HTTPFailIfBodyNotMatchesAnnotation:   "ok",
+				HTTPFailIfHeaderMatchesAnnotation:    "Server: ^Apache:.*",
+				HTTPFailIfHeaderNotMatchesAnnotation: "Content-Type:text/html",
+				HTTPFailIfNotSSLAnnotation:           "true",
+			},
+			expected: func() HTTPOptions {
+				opts := defaultCheckOptions.HTTP
+				opts.FailIfBodyMatchesRegexp = []string{"error", "failed, retry"}
+				opts.FailIfBodyNotMatchesRegexp = []string{"ok"}
+				opts.FailIfHeaderMatchesRegexp = []sm.HeaderMatch{{Header: "Server", Regexp: "^Apache:.*"}}
+				opts.FailIfHeaderNotMatchesRegexp = []sm.HeaderMatch{{Header: "Content-Type", Regexp: "text/html"}}
+				opts.FailIfNotSSL = true
+				return opts
+			}(),
+		},
+		"invalid values": {
+			annotations: map[string]string{
+				HTTPMethodAnnotation:            "FETCH",
+				HTTPValidStatusCodesAnnotation:  "2xx",
+				HTTPNoFollowRedirectsAnnotation: "maybe",
+				HTTPIPVersionAnnotation:         "v5",
+			},
+			expected: defaultCheckOptions.HTTP,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := NewOptions()
+			require.Equal(t, test.expected, opts.NewCheckOptions(test.annotations).HTTP)
+		})
+	}
+}
+
+func TestAlertOptions(t *testing.T) {
+	for name, test := range map[string]struct {
+		annotations      map[string]string
+		defaultLevel     string
+		defaultBasicOnly bool
+		sensitivity      string
+		basicOnly        bool
+	}{
+		"defaults": {},
+		"annotations": {
+			annotations: map[string]string{
+				AlertSensitivityAnnotation: "High",
+				BasicMetricsOnlyAnnotation: "true",
+			},
+			sensitivity: "high",
+			basicOnly:   true,
+		},
+		"cluster defaults": {
+			defaultLevel:     "low",
+			defaultBasicOnly: true,
+			sensitivity:      "low",
+			basicOnly:        true,
+		},
+		"annotations override cluster defaults": {
+			annotations: map[string]string{
+				AlertSensitivityAnnotation: "none",
+				BasicMetricsOnlyAnnotation: "false",
+			},
+			defaultLevel:     "medium",
+			defaultBasicOnly: true,
+			sensitivity:      "none",
+		},
+		"invalid values": {
+			annotations: map[string]string{
+				AlertSensitivityAnnotation: "critical",
+				BasicMetricsOnlyAnnotation: "sometimes",
+			},
+			defaultLevel: "medium",
+			sensitivity:  "medium",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := NewOptions()
+			opts.AlertSensitivity = test.defaultLevel
+			opts.BasicMetricsOnly = test.defaultBasicOnly
+			checkOpts := opts.NewCheckOptions(test.annotations)
+			require.Equal(t, test.sensitivity, checkOpts.AlertSensitivity)
+			require.Equal(t, test.basicOnly, checkOpts.BasicMetricsOnly)
+
+			check := checkOpts.newCheck("example.com")
+			require.Equal(t, test.sensitivity, check.AlertSensitivity)
+			require.Equal(t, test.basicOnly, check.BasicMetricsOnly)
+		})
+	}
+}
+
+func TestParseAlertSensitivity(t *testing.T) {
+	level, err := ParseAlertSensitivity(" Medium ")
+	require.NoError(t, err)
+	require.Equal(t, "medium", level)
+
+	level, err = ParseAlertSensitivity("")
+	require.NoError(t, err)
+	require.Empty(t, level)
+
+	_, err = ParseAlertSensitivity("urgent")
+	require.Error(t, err)
+}
+
+func TestProbeOptions(t *testing.T) {
+	for name, test := range map[string]struct {
+		config      map[string]string
+		annotations map[string]string
+		probes      []string
+		count       int
+	}{
+		"defaults": {
+			probes: defaultCheckOptions.Probes,
+		},
+		"selectors": {
+			annotations: map[string]string{
+				ProbesAnnotation: "region=EMEA, label:tier=gold,",
+			},
+			probes: []string{"region=EMEA", "label:tier=gold"},
+		},
+		"count only": {
+			annotations: map[string]string{
+				ProbeCountAnnotation: "3",
+			},
+			probes: defaultCheckOptions.Probes,
+			count:  3,
+		},
+		"count with config probes": {
+			config: map[string]string{
+				"probes": "region=EMEA",
+			},
+			annotations: map[string]string{
+				ProbeCountAnnotation: "1",
+			},
+			probes: []string{"region=EMEA"},
+			count:  1,
+		},
+		"count and selectors": {
+			annotations: map[string]string{
+				ProbesAnnotation:     "public",
+				ProbeCountAnnotation: "2",
+			},
+			probes: []string{"public"},
+			count:  2,
+		},
+		"invalid count": {
+			annotations: map[string]string{
+				ProbeCountAnnotation: "0",
+			},
+			probes: defaultCheckOptions.Probes,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts, err := ParseConfig(NewOptions(), test.config)
+			require.NoError(t, err)
+			checkOpts := opts.NewCheckOptions(test.annotations)
+			require.Equal(t, test.probes, checkOpts.Probes)
+			require.Equal(t, test.count, checkOpts.ProbeCount)
+		})
+	}
+}
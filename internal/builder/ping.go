@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"strconv"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+const maxPingPayloadSize = sm.MaxPingPayloadSize
+
+type PingMode uint8
+
+const (
+	// PingDisabled doesn't create ping checks.
+	PingDisabled PingMode = iota
+	// PingAlso creates a ping check per host in addition to the TCP checks.
+	PingAlso
+	// PingOnly creates a ping check per host instead of the TCP checks.
+	PingOnly
+)
+
+type PingOptions struct {
+	Mode         PingMode
+	PacketCount  int64
+	PayloadSize  int64
+	DontFragment bool
+	// IpVersion overrides the IP version chosen for the target when set.
+	IpVersion *sm.IpVersion
+}
+
+func parsePingOptions(annotations map[string]string, opts PingOptions) PingOptions {
+	mode := annotations[PingAnnotation]
+	if strings.EqualFold(mode, "only") {
+		opts.Mode = PingOnly
+	} else if enabled, err := strconv.ParseBool(mode); err == nil {
+		opts.Mode = PingDisabled
+		if enabled {
+			opts.Mode = PingAlso
+		}
+	}
+	if count, err := strconv.ParseInt(annotations[PingPacketCountAnnotation], 10, 64); err == nil && count > 0 && count <= sm.MaxPingPackets {
+		opts.PacketCount = count
+	}
+	if size, err := strconv.ParseInt(annotations[PingPayloadSizeAnnotation], 10, 64); err == nil && size >= 0 && size <= maxPingPayloadSize {
+		opts.PayloadSize = size
+	}
+	if dontFragment, err := strconv.ParseBool(annotations[PingDontFragmentAnnotation]); err == nil {
+		opts.DontFragment = dontFragment
+	}
+	if version, found := parseIpVersion(annotations[PingIPVersionAnnotation]); found {
+		opts.IpVersion = &version
+	}
+	return opts
+}
+
+func (opts *CheckOptions) pingCheckForHost(svc *coreV1.Service, host string) (*sm.Check, error) {
+	check := opts.newCheck(host)
+	if err := opts.nameCheck(check, svc, JobData{Host: host, Protocol: "ICMP"}); err != nil {
+		return nil, err
+	}
+
+	check.Settings.Ping = opts.pingSettings()
+
+	return check, nil
+}
+
+func (opts *CheckOptions) pingSettings() *sm.PingSettings {
+	return &sm.PingSettings{
+		IpVersion:    opts.Family.resolve(opts.Ping.IpVersion),
+		PacketCount:  opts.Ping.PacketCount,
+		PayloadSize:  opts.Ping.PayloadSize,
+		DontFragment: opts.Ping.DontFragment,
+	}
+}
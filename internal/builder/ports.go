@@ -0,0 +1,129 @@
+package builder
+
+import (
+	"strconv"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+)
+
+const (
+	portTypeTCP   = "tcp"
+	portTypeHTTP  = "http"
+	portTypeHTTPS = "https"
+)
+
+// PortOptions are the settings that can be overridden for a single service port, using annotations
+// of the form PortAnnotationsPrefix + "<port name or number>.<setting>".
+type PortOptions struct {
+	// Frequency and Timeout replace the ones in CheckOptions when non-zero.
+	Frequency int64
+	Timeout   int64
+	// Type is one of "tcp", "http" or "https".
+	Type string
+	// Path is the request path for HTTP checks.
+	Path string
+}
+
+func (p PortOptions) isHTTP() bool {
+	return p.Type == portTypeHTTP || p.Type == portTypeHTTPS
+}
+
+// parsePortOverrides collects the per-port annotations, keyed by port name or number, on top of the
+// inherited overrides. Each setting replaces only the same setting of the same port.
+func parsePortOverrides(annotations map[string]string, inherited map[string]PortOptions) map[string]PortOptions {
+	var overrides map[string]PortOptions
+	if len(inherited) > 0 {
+		overrides = make(map[string]PortOptions, len(inherited))
+		for port, portOpts := range inherited {
+			overrides[port] = portOpts
+		}
+	}
+	for key, value := range annotations {
+		rest := strings.TrimPrefix(key, PortAnnotationsPrefix)
+		if rest == key {
+			continue
+		}
+		port, setting, found := cutLast(rest, ".")
+		if !found || port == "" {
+			continue
+		}
+		if overrides == nil {
+			overrides = make(map[string]PortOptions)
+		}
+		portOpts := overrides[port]
+		switch setting {
+		case PortFrequencySetting:
+			if freq, err := parseFrequency(value); err == nil {
+				portOpts.Frequency = freq
+			}
+		case PortTimeoutSetting:
+			if timeout, err := parseTimeout(value); err == nil {
+				portOpts.Timeout = timeout
+			}
+		case PortTypeSetting:
+			switch t := strings.ToLower(value); t {
+			case portTypeTCP, portTypeHTTP, portTypeHTTPS:
+				portOpts.Type = t
+			}
+		case PortPathSetting:
+			portOpts.Path = value
+		}
+		overrides[port] = portOpts
+	}
+	return overrides
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if idx := strings.LastIndex(s, sep); idx >= 0 {
+		return s[:idx], s[idx+len(sep):], true
+	}
+	return s, "", false
+}
+
+// portSelected tells if a check must be created for the given port.
+func (opts *CheckOptions) portSelected(port coreV1.ServicePort) bool {
+	if len(opts.Ports) == 0 {
+		return true
+	}
+	number := strconv.Itoa(int(port.Port))
+	for _, p := range opts.Ports {
+		if (port.Name != "" && p == port.Name) || p == number {
+			return true
+		}
+	}
+	return false
+}
+
+// forPort returns a copy of the options with the overrides for the given port applied.
+// Overrides by port name take precedence over overrides by port number.
+func (opts *CheckOptions) forPort(port coreV1.ServicePort) *CheckOptions {
+	result := *opts
+	result.Port = PortOptions{
+		Type: portTypeTCP,
+		Path: "/",
+	}
+	keys := []string{strconv.Itoa(int(port.Port))}
+	if port.Name != "" {
+		keys = append(keys, port.Name)
+	}
+	for _, key := range keys {
+		override, found := opts.PortOverrides[key]
+		if !found {
+			continue
+		}
+		if override.Frequency != 0 {
+			result.Frequency = override.Frequency
+		}
+		if override.Timeout != 0 {
+			result.Timeout = override.Timeout
+		}
+		if override.Type != "" {
+			result.Port.Type = override.Type
+		}
+		if override.Path != "" {
+			result.Port.Path = override.Path
+		}
+	}
+	return &result
+}
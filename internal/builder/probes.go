@@ -0,0 +1,33 @@
+package builder
+
+import (
+	"strconv"
+
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+// parseProbes returns the probe selectors and probe count set in the annotations, on top of the
+// inherited ones.
+func parseProbes(annotations map[string]string, probes []string, count int) ([]string, int) {
+	var selectedCount int
+	if value, err := strconv.ParseUint(annotations[ProbeCountAnnotation], 10, 16); err == nil {
+		selectedCount = int(value)
+	}
+	return selectProbes(probes, count, splitList(annotations[ProbesAnnotation]), selectedCount)
+}
+
+// selectProbes applies the probes and probe count given in a layer to the inherited ones. When only
+// a probe count is given, the probes are chosen from the inherited probes, or from all the available
+// probes if none are inherited.
+func selectProbes(probes []string, count int, selected []string, selectedCount int) ([]string, int) {
+	if len(selected) > 0 {
+		probes = selected
+	}
+	if selectedCount > 0 {
+		count = selectedCount
+		if len(probes) == 0 {
+			probes = []string{sm.AllProbesSelector}
+		}
+	}
+	return probes, count
+}
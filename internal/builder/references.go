@@ -0,0 +1,78 @@
+package builder
+
+import (
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotations that reference Secrets and ConfigMaps in the namespace of the annotated object.
+var (
+	secretAnnotations    = []string{BasicAuthSecretAnnotation, BearerTokenSecretAnnotation, TLSSecretAnnotation}
+	configMapAnnotations = []string{K6ScriptConfigMapAnnotation}
+)
+
+// References are the Secrets and ConfigMaps that checks can read, so that changes to other Secrets and
+// ConfigMaps can be ignored.
+type References struct {
+	// Keyed by namespace and name, or only by name when referenced in every namespace.
+	secrets    map[string]bool
+	configMaps map[string]bool
+}
+
+// NewReferences returns the Secrets and ConfigMaps referenced by the annotations of the objects and
+// their namespaces, and by the defaults in the data of the config ConfigMap.
+func NewReferences(objs Objects, config map[string]string) *References {
+	refs := &References{
+		secrets:    make(map[string]bool),
+		configMaps: make(map[string]bool),
+	}
+
+	defaults := make(map[string]string, len(config))
+	for key, value := range config {
+		defaults[AnnotationsPrefix+key] = value
+	}
+	refs.add("", defaults)
+
+	for _, ns := range objs.Namespaces {
+		refs.add(ns.Name+"/", ns.Annotations)
+	}
+	var annotated []metaV1.Object
+	for _, svc := range objs.Services {
+		annotated = append(annotated, svc)
+	}
+	for _, ing := range objs.Ingresses {
+		annotated = append(annotated, ing)
+	}
+	for _, route := range objs.HTTPRoutes {
+		annotated = append(annotated, route)
+	}
+	for _, sc := range objs.SyntheticChecks {
+		annotated = append(annotated, sc)
+	}
+	for _, obj := range annotated {
+		refs.add(obj.GetNamespace()+"/", obj.GetAnnotations())
+	}
+	return refs
+}
+
+func (r *References) add(prefix string, annotations map[string]string) {
+	for _, key := range secretAnnotations {
+		if name := annotations[key]; name != "" {
+			r.secrets[prefix+name] = true
+		}
+	}
+	for _, key := range configMapAnnotations {
+		if name := annotations[key]; name != "" {
+			r.configMaps[prefix+name] = true
+		}
+	}
+}
+
+// Secret returns whether a Secret is referenced.
+func (r *References) Secret(namespace, name string) bool {
+	return r.secrets[namespace+"/"+name] || r.secrets[name]
+}
+
+// ConfigMap returns whether a ConfigMap is referenced.
+func (r *References) ConfigMap(namespace, name string) bool {
+	return r.configMaps[namespace+"/"+name] || r.configMaps[name]
+}
@@ -0,0 +1,72 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	coreV1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/adriansr/sm-controller/internal/crd"
+)
+
+func TestReferences(t *testing.T) {
+	refs := NewReferences(Objects{
+		Namespaces: []*coreV1.Namespace{{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:        "shop",
+				Annotations: map[string]string{TLSSecretAnnotation: "shop-ca"},
+			},
+		}},
+		Services: []*coreV1.Service{{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:        "web",
+				Namespace:   "shop",
+				Annotations: map[string]string{BasicAuthSecretAnnotation: "web-auth"},
+			},
+		}},
+		Ingresses: []*networkingV1.Ingress{{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:        "web",
+				Namespace:   "blog",
+				Annotations: map[string]string{K6ScriptConfigMapAnnotation: "scripts"},
+			},
+		}},
+		SyntheticChecks: []*crd.SyntheticCheck{{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:        "login",
+				Namespace:   "blog",
+				Annotations: map[string]string{BearerTokenSecretAnnotation: "token"},
+			},
+		}},
+	}, map[string]string{
+		"tls-secret": "cluster-ca",
+		"probes":     "Paris",
+	})
+
+	for name, test := range map[string]struct {
+		secret     bool
+		namespace  string
+		name       string
+		referenced bool
+	}{
+		"namespace secret":       {secret: true, namespace: "shop", name: "shop-ca", referenced: true},
+		"object secret":          {secret: true, namespace: "shop", name: "web-auth", referenced: true},
+		"synthetic check":        {secret: true, namespace: "blog", name: "token", referenced: true},
+		"config secret":          {secret: true, namespace: "other", name: "cluster-ca", referenced: true},
+		"other namespace":        {secret: true, namespace: "blog", name: "web-auth"},
+		"unreferenced secret":    {secret: true, namespace: "shop", name: "db-password"},
+		"object configmap":       {namespace: "blog", name: "scripts", referenced: true},
+		"secret name":            {namespace: "shop", name: "web-auth"},
+		"unreferenced configmap": {namespace: "blog", name: "kube-root-ca.crt"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if test.secret {
+				require.Equal(t, test.referenced, refs.Secret(test.namespace, test.name))
+			} else {
+				require.Equal(t, test.referenced, refs.ConfigMap(test.namespace, test.name))
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+
+	sm "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+// BearerTokenKey is the key of the token in the Secret referenced by BearerTokenSecretAnnotation.
+const BearerTokenKey = "token"
+
+// secretStore holds the Secrets available to the checks, keyed by namespace and name.
+type secretStore map[string]*coreV1.Secret
+
+func newSecretStore(secrets []*coreV1.Secret) secretStore {
+	store := make(secretStore, len(secrets))
+	for _, secret := range secrets {
+		store[secret.Namespace+"/"+secret.Name] = secret
+	}
+	return store
+}
+
+// value returns the value of a key in a Secret.
+func (s secretStore) value(namespace, name, key string) (string, error) {
+	secret, found := s[namespace+"/"+name]
+	if !found {
+		return "", fmt.Errorf("secret %s/%s not found", namespace, name)
+	}
+	if value, found := secret.Data[key]; found {
+		return string(value), nil
+	}
+	if value, found := secret.StringData[key]; found {
+		return value, nil
+	}
+	return "", fmt.Errorf("secret %s/%s has no %s key", namespace, name, key)
+}
+
+// resolveAuth reads the credentials for the HTTP checks from the Secrets referenced in the options.
+// Secrets are always taken from the namespace of the monitored object.
+func (opts *HTTPOptions) resolveAuth(namespace string, secrets secretStore) (err error) {
+	if opts.BasicAuthSecret != "" && opts.BearerTokenSecret != "" {
+		return errors.New("both basic auth and bearer token secrets are set")
+	}
+	if name := opts.BasicAuthSecret; name != "" {
+		auth := &sm.BasicAuth{}
+		if auth.Username, err = secrets.value(namespace, name, coreV1.BasicAuthUsernameKey); err != nil {
+			return fmt.Errorf("reading basic auth: %w", err)
+		}
+		if auth.Password, err = secrets.value(namespace, name, coreV1.BasicAuthPasswordKey); err != nil {
+			return fmt.Errorf("reading basic auth: %w", err)
+		}
+		opts.basicAuth = auth
+	}
+	if name := opts.BearerTokenSecret; name != "" {
+		if opts.bearerToken, err = secrets.value(namespace, name, BearerTokenKey); err != nil {
+			return fmt.Errorf("reading bearer token: %w", err)
+		}
+	}
+	return nil
+}
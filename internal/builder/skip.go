@@ -0,0 +1,43 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SkipReason is a code explaining why no check was created for an object or one of its ports.
+type SkipReason string
+
+const (
+	// SkipDisabled means that monitoring is disabled by annotation.
+	SkipDisabled SkipReason = "disabled"
+	// SkipNoHost means that no host could be determined for the object.
+	SkipNoHost SkipReason = "no-host"
+	// SkipUnsupportedProtocol means that the port protocol can't be checked.
+	SkipUnsupportedProtocol SkipReason = "unsupported-protocol"
+	// SkipPortNotSelected means that the port isn't listed in the ports annotation.
+	SkipPortNotSelected SkipReason = "port-not-selected"
+	// SkipNoNodePort means that the port has no node port allocated.
+	SkipNoNodePort SkipReason = "no-node-port"
+)
+
+// Skipped is used as the Warning cause when an object or port doesn't get a check.
+type Skipped struct {
+	Reason SkipReason
+	// Port is the name or number of the skipped port, empty when the whole object is skipped.
+	Port   string
+	Detail string
+}
+
+func (s *Skipped) Error() string {
+	var sb strings.Builder
+	sb.WriteString("skipped")
+	if s.Port != "" {
+		fmt.Fprintf(&sb, " port %s", s.Port)
+	}
+	fmt.Fprintf(&sb, ": %s", s.Reason)
+	if s.Detail != "" {
+		fmt.Fprintf(&sb, " (%s)", s.Detail)
+	}
+	return sb.String()
+}
@@ -0,0 +1,197 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+
+	"github.com/adriansr/sm-controller/internal/crd"
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+// Check types for SyntheticChecks.
+const (
+	checkTypeHTTP       = "http"
+	checkTypeTCP        = "tcp"
+	checkTypePing       = "ping"
+	checkTypeDNS        = "dns"
+	checkTypeTraceroute = "traceroute"
+	checkTypeK6         = "k6"
+)
+
+// syntheticCheckToCheck returns the check defined by a SyntheticCheck. The spec takes precedence over
+// the annotations on the SyntheticCheck and its namespace, which are used as defaults.
+func (b *Builder) syntheticCheckToCheck(sc *crd.SyntheticCheck, ns *coreV1.Namespace, secrets secretStore,
+	configMaps configMapStore,
+) (*sm.Check, []error, error) {
+	opts, warns := b.options.parseCheckOptions(sc, ns)
+	spec := &sc.Spec
+	if spec.Enabled != nil && !*spec.Enabled {
+		return nil, append(warns, &Skipped{Reason: SkipDisabled}), nil
+	}
+	if spec.Target == "" {
+		return nil, warns, errors.New("target is required")
+	}
+
+	var err error
+	if spec.Frequency != "" {
+		if opts.Frequency, err = parseFrequency(spec.Frequency); err != nil {
+			return nil, warns, fmt.Errorf("invalid frequency: %w", err)
+		}
+	}
+	if spec.Timeout != "" {
+		if opts.Timeout, err = parseTimeout(spec.Timeout); err != nil {
+			return nil, warns, fmt.Errorf("invalid timeout: %w", err)
+		}
+	}
+	if opts.Timeout > opts.Frequency {
+		return nil, warns, fmt.Errorf("timeout %dms is longer than frequency %dms", opts.Timeout, opts.Frequency)
+	}
+	opts.Probes, opts.ProbeCount = selectProbes(opts.Probes, opts.ProbeCount, spec.Probes, spec.ProbeCount)
+	if spec.AlertSensitivity != "" {
+		if opts.AlertSensitivity, err = ParseAlertSensitivity(spec.AlertSensitivity); err != nil {
+			return nil, warns, fmt.Errorf("invalid alert sensitivity: %w", err)
+		}
+	}
+	if spec.BasicMetricsOnly != nil {
+		opts.BasicMetricsOnly = *spec.BasicMetricsOnly
+	}
+	opts.JobName = spec.Job
+
+	labels := append([]sm.Label(nil), opts.Labels...)
+	for name, value := range spec.Labels {
+		labels = append(labels, sm.Label{Name: name, Value: value})
+	}
+	opts.Labels = labels
+
+	checkType, err := syntheticCheckType(spec)
+	if err != nil {
+		return nil, warns, err
+	}
+	resolveWarns, err := b.resolveOptions(&opts, sc, ns, secrets, configMaps)
+	warns = append(warns, resolveWarns...)
+	if err != nil {
+		return nil, warns, err
+	}
+
+	host := targetHost(spec.Target)
+	opts = *opts.forFamily(opts.familiesFor(nil, host)[0])
+	check := opts.newCheck(spec.Target)
+	if checkType == checkTypeTraceroute {
+		check.Frequency, check.Timeout = opts.Traceroute.Frequency, opts.Traceroute.Timeout
+	}
+	check.Owner = sc.Namespace + "/" + sc.Name
+
+	data := JobData{Host: host, Protocol: checkType}
+	if checkType == checkTypeHTTP || checkType == checkTypeK6 {
+		data.URL = spec.Target
+	}
+	if err := opts.nameCheck(check, sc, data); err != nil {
+		return nil, warns, err
+	}
+	// The target is explicit, only the job name is generated.
+	check.Target = spec.Target
+
+	if check.Settings, err = opts.syntheticCheckSettings(checkType, spec); err != nil {
+		return nil, warns, err
+	}
+	if err := check.Settings.Validate(); err != nil {
+		return nil, warns, fmt.Errorf("invalid %s settings: %w", checkType, err)
+	}
+	return check, warns, nil
+}
+
+// syntheticCheckType returns the check type, either given explicitly or from the settings.
+func syntheticCheckType(spec *crd.SyntheticCheckSpec) (string, error) {
+	var types []string
+	for checkType, set := range map[string]bool{
+		checkTypeHTTP:       spec.Settings.Http != nil,
+		checkTypeTCP:        spec.Settings.Tcp != nil,
+		checkTypePing:       spec.Settings.Ping != nil,
+		checkTypeDNS:        spec.Settings.Dns != nil,
+		checkTypeTraceroute: spec.Settings.Traceroute != nil,
+		checkTypeK6:         spec.Settings.K6 != nil,
+	} {
+		if set {
+			types = append(types, checkType)
+		}
+	}
+
+	checkType := strings.ToLower(spec.Type)
+	switch {
+	case len(types) > 1:
+		return "", fmt.Errorf("settings for more than one check type: %s", strings.Join(types, ", "))
+	case checkType == "" && len(types) == 0:
+		return "", errors.New("either the type or the settings are required")
+	case checkType == "":
+		return types[0], nil
+	case len(types) == 1 && types[0] != checkType:
+		return "", fmt.Errorf("%s settings given for a %s check", types[0], checkType)
+	}
+
+	switch checkType {
+	case checkTypeHTTP, checkTypeTCP, checkTypePing, checkTypeDNS, checkTypeTraceroute, checkTypeK6:
+		return checkType, nil
+	default:
+		return "", fmt.Errorf("unknown check type %s", spec.Type)
+	}
+}
+
+// syntheticCheckSettings returns the settings in the spec, or the default settings for the check type.
+func (opts *CheckOptions) syntheticCheckSettings(checkType string, spec *crd.SyntheticCheckSpec) (sm.CheckSettings, error) {
+	settings := spec.Settings
+	switch checkType {
+	case checkTypeHTTP:
+		if settings.Http == nil {
+			settings.Http = opts.httpSettings()
+		}
+
+	case checkTypeTCP:
+		if settings.Tcp == nil {
+			settings.Tcp = opts.tcpSettings()
+		}
+
+	case checkTypePing:
+		if settings.Ping == nil {
+			settings.Ping = opts.pingSettings()
+		}
+
+	case checkTypeDNS:
+		if settings.Dns == nil {
+			if err := opts.DNS.validate(); err != nil {
+				return settings, err
+			}
+			settings.Dns = opts.dnsSettings(nil)
+		}
+
+	case checkTypeTraceroute:
+		if settings.Traceroute == nil {
+			settings.Traceroute = opts.tracerouteSettings()
+		}
+
+	case checkTypeK6:
+		if settings.K6 == nil {
+			if !opts.K6.enabled() {
+				return settings, fmt.Errorf("k6 checks need a script in the settings or the %s annotation",
+					K6ScriptConfigMapAnnotation)
+			}
+			settings.K6 = opts.k6Settings()
+		}
+	}
+	return settings, nil
+}
+
+// targetHost returns the host in a check target, which can be a URL, a host and port or a host.
+func targetHost(target string) string {
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		return host
+	}
+	return target
+}
@@ -0,0 +1,192 @@
+package builder
+
+import (
+	"testing"
+
+	sm "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	"github.com/stretchr/testify/require"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/adriansr/sm-controller/internal/crd"
+)
+
+func TestSyntheticChecks(t *testing.T) {
+	disabled := false
+	ns := &coreV1.Namespace{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        "shop",
+			Annotations: map[string]string{ProbesAnnotation: "Paris", FrequencyAnnotation: "30s"},
+		},
+	}
+
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		spec        crd.SyntheticCheckSpec
+		expected    *sm.Check
+		warns       []string // Reported before err.
+		err         string
+		skips       []SkipReason
+	}{
+		"http settings": {
+			spec: crd.SyntheticCheckSpec{
+				Target:  "https://shop.example.com/login",
+				Timeout: "5s",
+				Labels:  map[string]string{"team": "checkout"},
+				Settings: sm.CheckSettings{
+					Http: &sm.HttpSettings{Method: sm.HttpMethod_POST},
+				},
+			},
+			expected: &sm.Check{
+				Job:       "k8s_shop/login_https://shop.example.com/login",
+				Target:    "https://shop.example.com/login",
+				Enabled:   true,
+				Frequency: 30000,
+				Timeout:   5000,
+				Labels:    []sm.Label{{Name: "team", Value: "checkout"}},
+				Settings: sm.CheckSettings{
+					Http: &sm.HttpSettings{Method: sm.HttpMethod_POST},
+				},
+			},
+		},
+		"type with default settings": {
+			annotations: map[string]string{PingPacketCountAnnotation: "3"},
+			spec: crd.SyntheticCheckSpec{
+				Type:   "ping",
+				Job:    "db ping",
+				Target: "10.0.0.5",
+				Probes: []string{"public"},
+			},
+			expected: &sm.Check{
+				Job:       "db ping",
+				Target:    "10.0.0.5",
+				Enabled:   true,
+				Frequency: 30000,
+				Timeout:   3000,
+				Settings: sm.CheckSettings{
+					Ping: &sm.PingSettings{IpVersion: sm.IpVersion_V4, PacketCount: 3},
+				},
+			},
+		},
+		"tcp": {
+			spec: crd.SyntheticCheckSpec{
+				Type:      "TCP",
+				Target:    "db.example.com:5432",
+				Frequency: "1m",
+			},
+			expected: &sm.Check{
+				Job:       "k8s_shop/login_db.example.com/tcp",
+				Target:    "db.example.com:5432",
+				Enabled:   true,
+				Frequency: 60000,
+				Timeout:   3000,
+				Settings: sm.CheckSettings{
+					Tcp: &sm.TcpSettings{IpVersion: sm.IpVersion_V4},
+				},
+			},
+		},
+		"probe count": {
+			spec: crd.SyntheticCheckSpec{
+				Type:       "tcp",
+				Target:     "db.example.com:5432",
+				ProbeCount: 1,
+			},
+			expected: &sm.Check{
+				Job:       "k8s_shop/login_db.example.com/tcp",
+				Target:    "db.example.com:5432",
+				Enabled:   true,
+				Frequency: 30000,
+				Timeout:   3000,
+				Settings: sm.CheckSettings{
+					Tcp: &sm.TcpSettings{IpVersion: sm.IpVersion_V4},
+				},
+			},
+		},
+		"disabled": {
+			spec: crd.SyntheticCheckSpec{
+				Enabled: &disabled,
+				Type:    "http",
+				Target:  "https://shop.example.com/",
+			},
+			skips: []SkipReason{SkipDisabled},
+		},
+		"no target": {
+			spec: crd.SyntheticCheckSpec{Type: "http"},
+			err:  "target is required",
+		},
+		"no type": {
+			spec: crd.SyntheticCheckSpec{Target: "https://shop.example.com/"},
+			err:  "either the type or the settings are required",
+		},
+		"unknown type": {
+			spec: crd.SyntheticCheckSpec{Type: "grpc", Target: "shop.example.com:443"},
+			err:  "unknown check type grpc",
+		},
+		"mismatched settings": {
+			spec: crd.SyntheticCheckSpec{
+				Type:     "tcp",
+				Target:   "shop.example.com:443",
+				Settings: sm.CheckSettings{Ping: &sm.PingSettings{}},
+			},
+			err: "ping settings given for a tcp check",
+		},
+		"invalid settings": {
+			spec: crd.SyntheticCheckSpec{
+				Target:   "10.0.0.5",
+				Settings: sm.CheckSettings{Ping: &sm.PingSettings{PacketCount: 100}},
+			},
+			err: "invalid ping settings: invalid ping packet count",
+		},
+		"k6 without script": {
+			spec: crd.SyntheticCheckSpec{Type: "k6", Target: "https://shop.example.com/"},
+			err:  "k6 checks need a script in the settings or the synthetics.grafana.com/k6-script-configmap annotation",
+		},
+		"k6 script not found": {
+			annotations: map[string]string{K6ScriptConfigMapAnnotation: "scripts"},
+			spec:        crd.SyntheticCheckSpec{Type: "k6", Target: "https://shop.example.com/"},
+			warns:       []string{"k6 script configmap shop/scripts not found"},
+			err:         "k6 checks need a script in the settings or the synthetics.grafana.com/k6-script-configmap annotation",
+		},
+		"timeout longer than frequency": {
+			spec: crd.SyntheticCheckSpec{Type: "http", Target: "https://shop.example.com/", Frequency: "2s", Timeout: "5s"},
+			err:  "timeout 5000ms is longer than frequency 2000ms",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sc := &crd.SyntheticCheck{
+				ObjectMeta: metaV1.ObjectMeta{Name: "login", Namespace: "shop", Annotations: test.annotations},
+				Spec:       test.spec,
+			}
+			b := NewBuilder(NewOptions())
+			checks, warns := b.Build(Objects{
+				Namespaces:      []*coreV1.Namespace{ns},
+				SyntheticChecks: []*crd.SyntheticCheck{sc},
+			})
+
+			if test.err != "" {
+				var messages []string
+				for _, w := range warns {
+					messages = append(messages, w.Cause.Error())
+				}
+				require.Equal(t, append(test.warns, test.err), messages)
+				require.Empty(t, checks)
+				return
+			}
+			require.Equal(t, test.skips, skipReasons(t, warns))
+			if test.expected == nil {
+				require.Empty(t, checks)
+				return
+			}
+			require.Len(t, checks, 1)
+			check := checks[0]
+			require.Equal(t, "shop/login", check.Owner)
+			if test.spec.Probes == nil {
+				require.Equal(t, []string{"Paris"}, check.Probes)
+			} else {
+				require.Equal(t, test.spec.Probes, check.Probes)
+			}
+			require.Equal(t, test.spec.ProbeCount, check.ProbeCount)
+			require.Equal(t, *test.expected, check.RawCheck)
+		})
+	}
+}
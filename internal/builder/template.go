@@ -0,0 +1,157 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	coreV1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/adriansr/sm-controller/internal/crd"
+	"github.com/adriansr/sm-controller/internal/gateway"
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+const (
+	// DefaultClusterName is used in job names when no cluster name is configured.
+	DefaultClusterName = "k8s"
+
+	// DefaultJobNameTemplate generates job names such as "k8s_default/web_10.0.0.1:http/HTTP".
+	DefaultJobNameTemplate = `{{.Cluster}}_{{.Namespace}}/{{.Name}}_` +
+		`{{if .URL}}{{.URL}}{{else}}{{.Host}}{{with .Port}}:{{.}}{{end}}/{{.Protocol}}{{with .IPFamily}}/{{.}}{{end}}{{end}}`
+
+	// DefaultTargetTemplate keeps the target chosen for each check type.
+	DefaultTargetTemplate = `{{.Target}}`
+)
+
+var (
+	defaultJobNameTemplate = template.Must(ParseTemplate("job", DefaultJobNameTemplate))
+	defaultTargetTemplate  = template.Must(ParseTemplate("target", DefaultTargetTemplate))
+)
+
+// JobData holds the fields available to the job name and target templates.
+type JobData struct {
+	Cluster   string
+	Kind      string
+	Namespace string
+	Name      string
+	Host      string
+	// Port is the port name, or number for unnamed ports.
+	Port string
+	// Protocol is one of TCP, HTTP, HTTPS, ICMP, DNS, traceroute or k6, or the type of a SyntheticCheck.
+	Protocol string
+	// Path and URL are only set for Ingress and HTTPRoute endpoints.
+	Path string
+	URL  string
+	// IPFamily is set for the checks created for each family of a dual-stack target.
+	IPFamily string
+	// Target is the default target for the check.
+	Target string
+}
+
+// sampleJobData is used to validate templates.
+var sampleJobData = JobData{
+	Cluster:   "cluster",
+	Kind:      "Service",
+	Namespace: "namespace",
+	Name:      "name",
+	Host:      "example.com",
+	Port:      "http",
+	Protocol:  "HTTP",
+	Path:      "/",
+	URL:       "http://example.com/",
+	IPFamily:  "IPv4",
+	Target:    "http://example.com:80/",
+}
+
+// ParseTemplate parses a job name or target template and checks that it produces a value.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	tpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	result, err := executeTemplate(tpl, sampleJobData)
+	if err != nil {
+		return nil, err
+	}
+	if result == "" {
+		return nil, errors.New("template produces an empty value")
+	}
+	return tpl, nil
+}
+
+func executeTemplate(tpl *template.Template, data JobData) (string, error) {
+	var sb strings.Builder
+	if err := tpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// checkTemplates holds the cluster identity and templates used to name checks.
+type checkTemplates struct {
+	cluster string
+	job     *template.Template
+	target  *template.Template
+}
+
+func (opt *Options) checkTemplates() checkTemplates {
+	t := checkTemplates{
+		cluster: opt.ClusterName,
+		job:     opt.JobNameTemplate,
+		target:  opt.TargetTemplate,
+	}
+	if t.cluster == "" {
+		t.cluster = DefaultClusterName
+	}
+	if t.job == nil {
+		t.job = defaultJobNameTemplate
+	}
+	if t.target == nil {
+		t.target = defaultTargetTemplate
+	}
+	return t
+}
+
+// nameCheck sets the job name and target of a check from the templates. The check's target is used
+// as the default target. Job names set with an annotation are kept.
+func (opts *CheckOptions) nameCheck(check *sm.Check, obj metaV1.Object, data JobData) error {
+	data.Cluster = opts.templates.cluster
+	data.Kind = objectKind(obj)
+	data.Namespace = obj.GetNamespace()
+	data.Name = obj.GetName()
+	data.IPFamily = opts.Family.Name
+	data.Target = check.Target
+
+	if check.Job == "" {
+		job, err := executeTemplate(opts.templates.job, data)
+		if err != nil {
+			return fmt.Errorf("building job name: %w", err)
+		}
+		check.Job = job
+	}
+	target, err := executeTemplate(opts.templates.target, data)
+	if err != nil {
+		return fmt.Errorf("building target: %w", err)
+	}
+	check.Target = target
+	return nil
+}
+
+func objectKind(obj metaV1.Object) string {
+	switch obj.(type) {
+	case *coreV1.Service:
+		return "Service"
+	case *networkingV1.Ingress:
+		return "Ingress"
+	case *gateway.HTTPRoute:
+		return gateway.HTTPRouteKind
+	case *crd.SyntheticCheck:
+		return crd.SyntheticCheckKind
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,85 @@
+package builder
+
+import (
+	"fmt"
+	"strconv"
+
+	sm "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+// TLSCAKey is the key of the CA bundle in the Secret referenced by TLSSecretAnnotation. Client
+// certificates use the kubernetes.io/tls Secret keys.
+const TLSCAKey = "ca.crt"
+
+type TLSOptions struct {
+	// Secret is the name of the Secret holding the CA bundle and client certificate.
+	Secret             string
+	ServerName         string
+	InsecureSkipVerify bool
+	// TCP enables TLS for TCP checks.
+	TCP bool
+
+	caCert     []byte
+	clientCert []byte
+	clientKey  []byte
+}
+
+func parseTLSOptions(annotations map[string]string, opts TLSOptions) TLSOptions {
+	if name := annotations[TLSSecretAnnotation]; name != "" {
+		opts.Secret = name
+	}
+	if serverName := annotations[TLSServerNameAnnotation]; serverName != "" {
+		opts.ServerName = serverName
+	}
+	if insecure, err := strconv.ParseBool(annotations[TLSInsecureSkipVerifyAnnotation]); err == nil {
+		opts.InsecureSkipVerify = insecure
+	}
+	if tcpTLS, err := strconv.ParseBool(annotations[TCPTLSAnnotation]); err == nil {
+		opts.TCP = tcpTLS
+	}
+	return opts
+}
+
+// resolve reads the certificates from the referenced Secret. Missing Secrets and keys are reported
+// as warnings and the checks are created without them.
+func (opts *TLSOptions) resolve(namespace string, secrets secretStore) (warns []error) {
+	if opts.Secret == "" {
+		return nil
+	}
+	secret, found := secrets[namespace+"/"+opts.Secret]
+	if !found {
+		return []error{fmt.Errorf("TLS secret %s/%s not found", namespace, opts.Secret)}
+	}
+
+	opts.caCert = secret.Data[TLSCAKey]
+	cert, key := secret.Data[coreV1.TLSCertKey], secret.Data[coreV1.TLSPrivateKeyKey]
+	switch {
+	case len(cert) > 0 && len(key) > 0:
+		opts.clientCert, opts.clientKey = cert, key
+	case len(cert) > 0:
+		warns = append(warns, fmt.Errorf("TLS secret %s/%s has a client certificate but no %s key",
+			namespace, opts.Secret, coreV1.TLSPrivateKeyKey))
+	case len(key) > 0:
+		warns = append(warns, fmt.Errorf("TLS secret %s/%s has a client key but no %s key",
+			namespace, opts.Secret, coreV1.TLSCertKey))
+	case len(opts.caCert) == 0:
+		warns = append(warns, fmt.Errorf("TLS secret %s/%s has none of the %s, %s or %s keys",
+			namespace, opts.Secret, TLSCAKey, coreV1.TLSCertKey, coreV1.TLSPrivateKeyKey))
+	}
+	return warns
+}
+
+// config returns the TLS settings for a check, or nil if there are none.
+func (opts *TLSOptions) config() *sm.TLSConfig {
+	if opts.ServerName == "" && !opts.InsecureSkipVerify && len(opts.caCert) == 0 && len(opts.clientCert) == 0 {
+		return nil
+	}
+	return &sm.TLSConfig{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		CACert:             opts.caCert,
+		ClientCert:         opts.clientCert,
+		ClientKey:          opts.clientKey,
+		ServerName:         opts.ServerName,
+	}
+}
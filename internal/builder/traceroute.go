@@ -0,0 +1,64 @@
+package builder
+
+import (
+	"strconv"
+
+	coreV1 "k8s.io/api/core/v1"
+
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+type TracerouteOptions struct {
+	Enabled bool
+
+	// Frequency and Timeout replace the ones in CheckOptions for traceroute checks.
+	Frequency int64
+	Timeout   int64
+
+	MaxHops        int64
+	MaxUnknownHops int64
+	HopTimeout     int64
+	PtrLookup      bool
+}
+
+func parseTracerouteOptions(annotations map[string]string, opts TracerouteOptions) TracerouteOptions {
+	if enabled, err := strconv.ParseBool(annotations[TracerouteAnnotation]); err == nil {
+		opts.Enabled = enabled
+	}
+	if hops, err := strconv.ParseUint(annotations[TracerouteMaxHopsAnnotation], 10, 8); err == nil && hops > 0 {
+		opts.MaxHops = int64(hops)
+	}
+	if hops, err := strconv.ParseUint(annotations[TracerouteMaxUnknownHopsAnnotation], 10, 8); err == nil {
+		opts.MaxUnknownHops = int64(hops)
+	}
+	if timeout, err := parseDuration(annotations[TracerouteHopTimeoutAnnotation]); err == nil {
+		opts.HopTimeout = timeout
+	}
+	if ptrLookup, err := strconv.ParseBool(annotations[TraceroutePTRLookupAnnotation]); err == nil {
+		opts.PtrLookup = ptrLookup
+	}
+	return opts
+}
+
+func (opts *CheckOptions) tracerouteCheckForHost(svc *coreV1.Service, host string) (*sm.Check, error) {
+	check := opts.newCheck(host)
+	check.Frequency = opts.Traceroute.Frequency
+	check.Timeout = opts.Traceroute.Timeout
+
+	if err := opts.nameCheck(check, svc, JobData{Host: host, Protocol: "traceroute"}); err != nil {
+		return nil, err
+	}
+
+	check.Settings.Traceroute = opts.tracerouteSettings()
+
+	return check, nil
+}
+
+func (opts *CheckOptions) tracerouteSettings() *sm.TracerouteSettings {
+	return &sm.TracerouteSettings{
+		MaxHops:        opts.Traceroute.MaxHops,
+		MaxUnknownHops: opts.Traceroute.MaxUnknownHops,
+		HopTimeout:     opts.Traceroute.HopTimeout,
+		PtrLookup:      opts.Traceroute.PtrLookup,
+	}
+}
@@ -0,0 +1,340 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sm "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Check frequency and timeout limits accepted by the API, in milliseconds.
+const (
+	MinFrequency = 1000
+	MaxFrequency = 120000
+	MinTimeout   = 1000
+	MaxTimeout   = 10000
+)
+
+var errUnknownAnnotation = errors.New("unknown annotation")
+
+// InvalidAnnotation is used as the Warning cause for annotations that can't be used. The annotation
+// is ignored and the default value is used instead.
+type InvalidAnnotation struct {
+	Annotation string
+	Value      string
+	Err        error
+}
+
+func (e *InvalidAnnotation) Error() string {
+	if errors.Is(e.Err, errUnknownAnnotation) {
+		return fmt.Sprintf("unknown annotation %s", e.Annotation)
+	}
+	return fmt.Sprintf("invalid annotation %s=%q: %v", e.Annotation, e.Value, e.Err)
+}
+
+func (e *InvalidAnnotation) Unwrap() error {
+	return e.Err
+}
+
+// parseDuration parses a duration either as a number of milliseconds or in Go's duration
+// syntax, such as "30s" or "2m".
+func parseDuration(value string) (int64, error) {
+	if ms, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return int64(ms), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	if d < 0 || d%time.Millisecond != 0 {
+		return 0, fmt.Errorf("duration %s must be a positive number of milliseconds", d)
+	}
+	return d.Milliseconds(), nil
+}
+
+// parseDurationInRange parses a duration that must be within [min, max] milliseconds.
+func parseDurationInRange(value string, min, max int64) (int64, error) {
+	ms, err := parseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if ms < min || ms > max {
+		return 0, fmt.Errorf("must be between %s and %s", time.Duration(min)*time.Millisecond, time.Duration(max)*time.Millisecond)
+	}
+	return ms, nil
+}
+
+func parseFrequency(value string) (int64, error) {
+	return parseDurationInRange(value, MinFrequency, MaxFrequency)
+}
+
+func parseTimeout(value string) (int64, error) {
+	return parseDurationInRange(value, MinTimeout, MaxTimeout)
+}
+
+type validator func(value string) error
+
+func validBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
+func validDuration(value string) error {
+	_, err := parseDuration(value)
+	return err
+}
+
+func validFrequency(value string) error {
+	_, err := parseFrequency(value)
+	return err
+}
+
+func validTimeout(value string) error {
+	_, err := parseTimeout(value)
+	return err
+}
+
+func validUint(min, max uint64) validator {
+	return func(value string) error {
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		if n < min || n > max {
+			return fmt.Errorf("must be between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
+func validOneOf(values ...string) validator {
+	return func(value string) error {
+		for _, v := range values {
+			if strings.EqualFold(v, value) {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}
+}
+
+func validEnum(values map[string]int32) validator {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return validOneOf(names...)
+}
+
+func validAny(string) error {
+	return nil
+}
+
+func validAll(validators ...validator) validator {
+	return func(value string) error {
+		for _, v := range validators {
+			if err := v(value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func validList(elem validator) validator {
+	return func(value string) error {
+		for _, item := range splitList(value) {
+			if err := elem(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func validNotEmpty(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return errors.New("must not be empty")
+	}
+	return nil
+}
+
+var validIPVersion = validEnum(sm.IpVersion_value)
+
+// annotationValidators holds the known annotations and how their values are checked. Some values,
+// such as regular expressions, are validated when building the checks instead.
+var annotationValidators = map[string]validator{
+	EnabledAnnotation:          validBool,
+	NameAnnotation:             validNotEmpty,
+	FrequencyAnnotation:        validFrequency,
+	TimeoutAnnotation:          validTimeout,
+	ProbesAnnotation:           validNotEmpty,
+	ProbeCountAnnotation:       validUint(1, 1<<16-1),
+	HostAnnotation:             validNotEmpty,
+	LabelsAnnotation:           validAny,
+	AlertSensitivityAnnotation: validAll(validNotEmpty, func(value string) error { _, err := ParseAlertSensitivity(value); return err }),
+	BasicMetricsOnlyAnnotation: validBool,
+
+	HTTPMethodAnnotation:            validEnum(sm.HttpMethod_value),
+	HTTPHeadersAnnotation:           validAny,
+	HTTPBodyAnnotation:              validAny,
+	HTTPValidStatusCodesAnnotation:  validList(validUint(100, 599)),
+	HTTPValidVersionsAnnotation:     validAny,
+	HTTPFollowRedirectsAnnotation:   validBool,
+	HTTPNoFollowRedirectsAnnotation: validBool,
+	HTTPCacheBustingAnnotation:      validNotEmpty,
+	HTTPIPVersionAnnotation:         validIPVersion,
+	BasicAuthSecretAnnotation:       validNotEmpty,
+	BearerTokenSecretAnnotation:     validNotEmpty,
+
+	TLSSecretAnnotation:             validNotEmpty,
+	TLSServerNameAnnotation:         validNotEmpty,
+	TLSInsecureSkipVerifyAnnotation: validBool,
+	TCPTLSAnnotation:                validBool,
+
+	HTTPFailIfBodyMatchesAnnotation:      validAny,
+	HTTPFailIfBodyNotMatchesAnnotation:   validAny,
+	HTTPFailIfHeaderMatchesAnnotation:    validAny,
+	HTTPFailIfHeaderNotMatchesAnnotation: validAny,
+	HTTPFailIfSSLAnnotation:              validBool,
+	HTTPFailIfNotSSLAnnotation:           validBool,
+
+	PingAnnotation: func(value string) error {
+		if strings.EqualFold(value, "only") {
+			return nil
+		}
+		return validBool(value)
+	},
+	PingPacketCountAnnotation:  validUint(1, sm.MaxPingPackets),
+	PingPayloadSizeAnnotation:  validUint(0, maxPingPayloadSize),
+	PingDontFragmentAnnotation: validBool,
+	PingIPVersionAnnotation:    validIPVersion,
+
+	DNSAnnotation:                   validBool,
+	DNSRecordTypeAnnotation:         validEnum(sm.DnsRecordType_value),
+	DNSServerAnnotation:             validNotEmpty,
+	DNSPortAnnotation:               validUint(1, 1<<16-1),
+	DNSProtocolAnnotation:           validEnum(sm.DnsProtocol_value),
+	DNSIPVersionAnnotation:          validIPVersion,
+	DNSExpectedAnswersAnnotation:    validAny,
+	DNSExpectLoadBalancerAnnotation: validBool,
+
+	TracerouteAnnotation:               validBool,
+	TracerouteMaxHopsAnnotation:        validUint(1, 1<<8-1),
+	TracerouteMaxUnknownHopsAnnotation: validUint(0, 1<<8-1),
+	TracerouteHopTimeoutAnnotation:     validDuration,
+	TraceroutePTRLookupAnnotation:      validBool,
+
+	K6ScriptConfigMapAnnotation: validNotEmpty,
+	K6ScriptKeyAnnotation:       validNotEmpty,
+
+	NodeCountAnnotation: func(value string) error {
+		if strings.EqualFold(value, "all") {
+			return nil
+		}
+		return validUint(1, 1<<16-1)(value)
+	},
+	NodeSelectorAnnotation: validAny,
+	NodeAddressTypeAnnotation: func(value string) error {
+		switch coreV1.NodeAddressType(value) {
+		case coreV1.NodeHostName, coreV1.NodeExternalIP, coreV1.NodeInternalIP, coreV1.NodeExternalDNS, coreV1.NodeInternalDNS:
+			return nil
+		}
+		return fmt.Errorf("must be one of %s, %s, %s, %s or %s", coreV1.NodeHostName, coreV1.NodeExternalIP,
+			coreV1.NodeInternalIP, coreV1.NodeExternalDNS, coreV1.NodeInternalDNS)
+	},
+
+	IPVersionAnnotation:          validIPVersion,
+	IPVersionPerFamilyAnnotation: validBool,
+
+	PortsAnnotation: validNotEmpty,
+}
+
+var portSettingValidators = map[string]validator{
+	PortFrequencySetting: validFrequency,
+	PortTimeoutSetting:   validTimeout,
+	PortTypeSetting:      validOneOf(portTypeTCP, portTypeHTTP, portTypeHTTPS),
+	PortPathSetting:      validNotEmpty,
+}
+
+// validateAnnotation checks a single annotation, which must have the annotations prefix.
+func validateAnnotation(key, value string) error {
+	check, found := annotationValidators[key]
+	if rest := strings.TrimPrefix(key, PortAnnotationsPrefix); rest != key {
+		port, setting, ok := cutLast(rest, ".")
+		check, found = portSettingValidators[setting]
+		found = found && ok && port != ""
+	}
+	if !found {
+		return &InvalidAnnotation{Annotation: key, Value: value, Err: errUnknownAnnotation}
+	}
+	if err := check(value); err != nil {
+		return &InvalidAnnotation{Annotation: key, Value: value, Err: err}
+	}
+	return nil
+}
+
+// validateAnnotations checks all the annotations with the annotations prefix, in key order.
+func validateAnnotations(annotations map[string]string) (errs []error) {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		if strings.HasPrefix(key, AnnotationsPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := validateAnnotation(key, annotations[key]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// parseCheckOptions returns the options for the checks of an object, using its namespace's annotations
+// as defaults, and the problems found in the annotations.
+func (opt *Options) parseCheckOptions(obj metaV1.Object, ns *coreV1.Namespace) (CheckOptions, []error) {
+	nsAnnotations, nsErrs := namespaceAnnotations(ns)
+	opts := opt.NewCheckOptions(nsAnnotations, obj.GetAnnotations())
+
+	var warns []error
+	for _, err := range append(nsErrs, validateAnnotations(nsAnnotations)...) {
+		warns = append(warns, fmt.Errorf("namespace %s: %w", ns.Name, err))
+	}
+	warns = append(warns, validateAnnotations(obj.GetAnnotations())...)
+
+	if opts.Timeout > opts.Frequency {
+		warns = append(warns, fmt.Errorf("timeout %dms is longer than frequency %dms, using the frequency as timeout",
+			opts.Timeout, opts.Frequency))
+		opts.Timeout = opts.Frequency
+	}
+	ports := make([]string, 0, len(opts.PortOverrides))
+	for port := range opts.PortOverrides {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		override := opts.PortOverrides[port]
+		frequency, timeout := opts.Frequency, opts.Timeout
+		if override.Frequency != 0 {
+			frequency = override.Frequency
+		}
+		if override.Timeout != 0 {
+			timeout = override.Timeout
+		}
+		if timeout > frequency {
+			warns = append(warns, fmt.Errorf("port %s timeout %dms is longer than frequency %dms, using the frequency as timeout",
+				port, timeout, frequency))
+			override.Timeout = frequency
+			opts.PortOverrides[port] = override
+		}
+	}
+	return opts, warns
+}
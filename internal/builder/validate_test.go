@@ -0,0 +1,152 @@
+package builder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseDuration(t *testing.T) {
+	for value, expected := range map[string]int64{
+		"1500":   1500,
+		"30s":    30000,
+		"2m":     120000,
+		"1.5s":   1500,
+		"250ms":  250,
+		"1m30s":  90000,
+		"-1s":    -1,
+		"1us":    -1,
+		"soon":   -1,
+		"":       -1,
+		"10 sec": -1,
+	} {
+		t.Run(value, func(t *testing.T) {
+			ms, err := parseDuration(value)
+			if expected < 0 {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, expected, ms)
+		})
+	}
+}
+
+func TestValidateAnnotations(t *testing.T) {
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		invalid     []string
+	}{
+		"valid": {
+			annotations: map[string]string{
+				EnabledAnnotation:                        "true",
+				FrequencyAnnotation:                      "30s",
+				TimeoutAnnotation:                        "5000",
+				HTTPMethodAnnotation:                     "post",
+				PingAnnotation:                           "only",
+				NodeCountAnnotation:                      "all",
+				PortAnnotationsPrefix + "http.frequency": "1m",
+				PortAnnotationsPrefix + "8080.type":      "https",
+				"example.com/unrelated":                  "ignored",
+			},
+		},
+		"invalid values": {
+			annotations: map[string]string{
+				EnabledAnnotation:                   "yes",
+				FrequencyAnnotation:                 "500ms",
+				TimeoutAnnotation:                   "1h",
+				HTTPValidStatusCodesAnnotation:      "200,2xx",
+				PingPacketCountAnnotation:           "100",
+				DNSRecordTypeAnnotation:             "AAAAA",
+				NodeAddressTypeAnnotation:           "externalip",
+				PortAnnotationsPrefix + "http.type": "grpc",
+			},
+			invalid: []string{
+				DNSRecordTypeAnnotation,
+				EnabledAnnotation,
+				FrequencyAnnotation,
+				HTTPValidStatusCodesAnnotation,
+				NodeAddressTypeAnnotation,
+				PingPacketCountAnnotation,
+				PortAnnotationsPrefix + "http.type",
+				TimeoutAnnotation,
+			},
+		},
+		"unknown": {
+			annotations: map[string]string{
+				AnnotationsPrefix + "frequncy":        "30s",
+				PortAnnotationsPrefix + "http.method": "GET",
+				PortAnnotationsPrefix + "frequency":   "30s",
+			},
+			invalid: []string{
+				AnnotationsPrefix + "frequncy",
+				PortAnnotationsPrefix + "frequency",
+				PortAnnotationsPrefix + "http.method",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var invalid []string
+			for _, err := range validateAnnotations(test.annotations) {
+				var annErr *InvalidAnnotation
+				require.True(t, errors.As(err, &annErr), err)
+				invalid = append(invalid, annErr.Annotation)
+			}
+			require.Equal(t, test.invalid, invalid)
+		})
+	}
+}
+
+func TestParseCheckOptions(t *testing.T) {
+	ns := &coreV1.Namespace{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: "shop",
+			Annotations: map[string]string{
+				AnnotationsPrefix + "probez": "Paris",
+				EnabledAnnotation:            "true",
+				NameAnnotation:               "shop",
+				HostAnnotation:               "shop.example.com",
+			},
+		},
+	}
+	svc := &coreV1.Service{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "web",
+			Namespace: "shop",
+			Annotations: map[string]string{
+				EnabledAnnotation:                        "true",
+				FrequencyAnnotation:                      "5s",
+				TimeoutAnnotation:                        "bad",
+				PortAnnotationsPrefix + "http.timeout":   "8s",
+				PortAnnotationsPrefix + "http.frequency": "2m",
+				PortAnnotationsPrefix + "grpc.timeout":   "9s",
+			},
+		},
+	}
+
+	opts := NewOptions()
+	checkOpts, warns := opts.parseCheckOptions(svc, ns)
+	require.Equal(t, int64(5000), checkOpts.Frequency)
+	require.Equal(t, defaultCheckOptions.Timeout, checkOpts.Timeout)
+	require.Equal(t, int64(8000), checkOpts.PortOverrides["http"].Timeout)
+	require.Equal(t, int64(5000), checkOpts.PortOverrides["grpc"].Timeout)
+
+	require.True(t, checkOpts.Enabled)
+	require.Empty(t, checkOpts.JobName)
+	require.Empty(t, checkOpts.Host)
+
+	var messages []string
+	for _, warn := range warns {
+		messages = append(messages, warn.Error())
+	}
+	require.Equal(t, []string{
+		`namespace shop: invalid annotation synthetics.grafana.com/host="shop.example.com": can't be set on a namespace`,
+		`namespace shop: invalid annotation synthetics.grafana.com/name="shop": can't be set on a namespace`,
+		"namespace shop: unknown annotation synthetics.grafana.com/probez",
+		`invalid annotation synthetics.grafana.com/timeout="bad": invalid duration "bad"`,
+		"port grpc timeout 9000ms is longer than frequency 5000ms, using the frequency as timeout",
+	}, messages)
+}
@@ -0,0 +1,51 @@
+package crd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// StatusWriter updates the status subresource of SyntheticChecks.
+type StatusWriter struct {
+	client dynamic.Interface
+}
+
+func NewStatusWriter(client dynamic.Interface) *StatusWriter {
+	return &StatusWriter{client: client}
+}
+
+// UpdateStatus replaces the status of a SyntheticCheck.
+func (w *StatusWriter) UpdateStatus(ctx context.Context, sc *SyntheticCheck, status SyntheticCheckStatus) error {
+	// Empty fields are set to null, as a merge patch keeps the fields it doesn't have.
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"observedGeneration": orNull(status.ObservedGeneration),
+			"job":                orNull(status.Job),
+			"checkID":            orNull(status.CheckID),
+			"lastSyncTime":       status.LastSyncTime,
+			"lastError":          orNull(status.LastError),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.client.Resource(SyntheticCheckResource.GroupVersionResource()).Namespace(sc.Namespace).
+		Patch(ctx, sc.Name, types.MergePatchType, patch, metaV1.PatchOptions{}, "status")
+	if err != nil {
+		return fmt.Errorf("updating status of %s %s/%s: %w", SyntheticCheckKind, sc.Namespace, sc.Name, err)
+	}
+	return nil
+}
+
+func orNull[T comparable](value T) interface{} {
+	var zero T
+	if value == zero {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,86 @@
+// Package crd holds the SyntheticCheck custom resource, used to define checks explicitly instead of
+// through annotations. It's watched with a dynamic informer and decoded from unstructured objects.
+package crd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	sm "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/adriansr/sm-controller/internal/schema"
+)
+
+const (
+	Group   = "synthetics.grafana.com"
+	Version = "v1alpha1"
+
+	SyntheticCheckKind = "SyntheticCheck"
+)
+
+// SyntheticCheckResource is the resource for SyntheticChecks.
+var SyntheticCheckResource = schema.Resource{
+	Group:   Group,
+	Version: Version,
+	Kind:    SyntheticCheckKind,
+	Plural:  "syntheticchecks",
+}
+
+type SyntheticCheck struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SyntheticCheckSpec   `json:"spec"`
+	Status SyntheticCheckStatus `json:"status,omitempty"`
+}
+
+// SyntheticCheckSpec mirrors a Synthetic Monitoring check. Unset fields take the defaults from the
+// controller, namespace and object annotations.
+type SyntheticCheckSpec struct {
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Job is generated from the job name template when empty.
+	Job string `json:"job,omitempty"`
+	// Type is one of http, tcp, ping, dns, traceroute or k6. It can be omitted when the settings
+	// for the type are given.
+	Type   string `json:"type,omitempty"`
+	Target string `json:"target"`
+	// Frequency and Timeout are given in milliseconds or Go's duration syntax, such as "30s".
+	Frequency        string            `json:"frequency,omitempty"`
+	Timeout          string            `json:"timeout,omitempty"`
+	Probes           []string          `json:"probes,omitempty"`
+	ProbeCount       int               `json:"probeCount,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	AlertSensitivity string            `json:"alertSensitivity,omitempty"`
+	BasicMetricsOnly *bool             `json:"basicMetricsOnly,omitempty"`
+	// Settings use the Synthetic Monitoring API format, with at most the settings for one type.
+	Settings sm.CheckSettings `json:"settings,omitempty"`
+}
+
+// SyntheticCheckStatus records the result of the last sync of a SyntheticCheck.
+type SyntheticCheckStatus struct {
+	ObservedGeneration int64        `json:"observedGeneration,omitempty"`
+	Job                string       `json:"job,omitempty"`
+	CheckID            int64        `json:"checkID,omitempty"`
+	LastSyncTime       *metaV1.Time `json:"lastSyncTime,omitempty"`
+	LastError          string       `json:"lastError,omitempty"`
+}
+
+// Decode returns the SyntheticCheck in an unstructured object. The check settings are decoded as JSON,
+// as their enums are given by name.
+func Decode(obj *unstructured.Unstructured) (*SyntheticCheck, error) {
+	if kind := obj.GetKind(); kind != SyntheticCheckKind {
+		return nil, fmt.Errorf("unexpected kind %s", kind)
+	}
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	sc := &SyntheticCheck{}
+	if err := json.Unmarshal(data, sc); err != nil {
+		return nil, fmt.Errorf("decoding %s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+	return sc, nil
+}
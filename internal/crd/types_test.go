@@ -0,0 +1,93 @@
+package crd
+
+import (
+	"testing"
+
+	sm "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	"github.com/stretchr/testify/require"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDecode(t *testing.T) {
+	enabled := false
+	for name, test := range map[string]struct {
+		obj      map[string]interface{}
+		expected *SyntheticCheck
+		err      bool
+	}{
+		"http": {
+			obj: map[string]interface{}{
+				"apiVersion": "synthetics.grafana.com/v1alpha1",
+				"kind":       "SyntheticCheck",
+				"metadata":   map[string]interface{}{"name": "login", "namespace": "shop", "generation": int64(3)},
+				"spec": map[string]interface{}{
+					"enabled":   false,
+					"target":    "https://shop.example.com/login",
+					"frequency": "30s",
+					"probes":    []interface{}{"public"},
+					"labels":    map[string]interface{}{"team": "checkout"},
+					"settings": map[string]interface{}{
+						"http": map[string]interface{}{
+							"method":           "POST",
+							"ipVersion":        "V4",
+							"validStatusCodes": []interface{}{int64(200), int64(302)},
+						},
+					},
+				},
+				"status": map[string]interface{}{
+					"checkID": int64(42),
+				},
+			},
+			expected: &SyntheticCheck{
+				TypeMeta:   metaV1.TypeMeta{APIVersion: "synthetics.grafana.com/v1alpha1", Kind: SyntheticCheckKind},
+				ObjectMeta: metaV1.ObjectMeta{Name: "login", Namespace: "shop", Generation: 3},
+				Spec: SyntheticCheckSpec{
+					Enabled:   &enabled,
+					Target:    "https://shop.example.com/login",
+					Frequency: "30s",
+					Probes:    []string{"public"},
+					Labels:    map[string]string{"team": "checkout"},
+					Settings: sm.CheckSettings{
+						Http: &sm.HttpSettings{
+							Method:           sm.HttpMethod_POST,
+							IpVersion:        sm.IpVersion_V4,
+							ValidStatusCodes: []int32{200, 302},
+						},
+					},
+				},
+				Status: SyntheticCheckStatus{CheckID: 42},
+			},
+		},
+		"invalid enum": {
+			obj: map[string]interface{}{
+				"apiVersion": "synthetics.grafana.com/v1alpha1",
+				"kind":       "SyntheticCheck",
+				"metadata":   map[string]interface{}{"name": "login", "namespace": "shop"},
+				"spec": map[string]interface{}{
+					"target":   "https://shop.example.com/login",
+					"settings": map[string]interface{}{"http": map[string]interface{}{"method": "FETCH"}},
+				},
+			},
+			err: true,
+		},
+		"unexpected kind": {
+			obj: map[string]interface{}{
+				"apiVersion": "synthetics.grafana.com/v1alpha1",
+				"kind":       "Probe",
+				"metadata":   map[string]interface{}{"name": "probe", "namespace": "shop"},
+			},
+			err: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sc, err := Decode(&unstructured.Unstructured{Object: test.obj})
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, sc)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Package gateway holds the parts of the Gateway API resources used to build checks. As the Gateway
+// API is made of CRDs, they are watched with dynamic informers and decoded from unstructured objects.
+package gateway
+
+import (
+	"fmt"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/adriansr/sm-controller/internal/schema"
+)
+
+const (
+	Group = "gateway.networking.k8s.io"
+
+	GatewayKind   = "Gateway"
+	HTTPRouteKind = "HTTPRoute"
+
+	GatewayPlural   = "gateways"
+	HTTPRoutePlural = "httproutes"
+)
+
+// Versions are the supported Gateway API versions, in order of preference.
+var Versions = []string{"v1", "v1beta1"}
+
+// Resources returns the Gateway and HTTPRoute resources for an API version.
+func Resources(version string) (gateways, httpRoutes schema.Resource) {
+	gateways = schema.Resource{
+		Group:   Group,
+		Version: version,
+		Kind:    GatewayKind,
+		Plural:  GatewayPlural,
+	}
+	httpRoutes = schema.Resource{
+		Group:   Group,
+		Version: version,
+		Kind:    HTTPRouteKind,
+		Plural:  HTTPRoutePlural,
+	}
+	return gateways, httpRoutes
+}
+
+type Gateway struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GatewaySpec   `json:"spec"`
+	Status GatewayStatus `json:"status,omitempty"`
+}
+
+type GatewaySpec struct {
+	Listeners []Listener `json:"listeners"`
+}
+
+type Listener struct {
+	Name     string `json:"name"`
+	Hostname string `json:"hostname,omitempty"`
+	Port     int32  `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+type GatewayStatus struct {
+	Addresses []GatewayAddress `json:"addresses,omitempty"`
+}
+
+type GatewayAddress struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value"`
+}
+
+type HTTPRoute struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HTTPRouteSpec `json:"spec"`
+}
+
+type HTTPRouteSpec struct {
+	ParentRefs []ParentReference `json:"parentRefs,omitempty"`
+	Hostnames  []string          `json:"hostnames,omitempty"`
+	Rules      []HTTPRouteRule   `json:"rules,omitempty"`
+}
+
+// ParentReference selects a Gateway, or one of its listeners by name or port. Empty fields take the
+// API defaults, including the route's namespace.
+type ParentReference struct {
+	Group       string `json:"group,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name"`
+	SectionName string `json:"sectionName,omitempty"`
+	Port        int32  `json:"port,omitempty"`
+}
+
+// IsGateway returns whether the reference is to a Gateway.
+func (r ParentReference) IsGateway() bool {
+	return (r.Group == "" || r.Group == Group) && (r.Kind == "" || r.Kind == GatewayKind)
+}
+
+type HTTPRouteRule struct {
+	Matches []HTTPRouteMatch `json:"matches,omitempty"`
+}
+
+type HTTPRouteMatch struct {
+	Path *HTTPPathMatch `json:"path,omitempty"`
+}
+
+// Path match types.
+const (
+	PathMatchExact             = "Exact"
+	PathMatchPathPrefix        = "PathPrefix"
+	PathMatchRegularExpression = "RegularExpression"
+)
+
+type HTTPPathMatch struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// Decode returns the Gateway or HTTPRoute in an unstructured object.
+func Decode(obj *unstructured.Unstructured) (interface{}, error) {
+	var out interface{}
+	switch kind := obj.GetKind(); kind {
+	case GatewayKind:
+		out = &Gateway{}
+	case HTTPRouteKind:
+		out = &HTTPRoute{}
+	default:
+		return nil, fmt.Errorf("unexpected kind %s", kind)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), out); err != nil {
+		return nil, fmt.Errorf("decoding %s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+	return out, nil
+}
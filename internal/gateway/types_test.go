@@ -0,0 +1,101 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDecode(t *testing.T) {
+	for name, test := range map[string]struct {
+		obj      map[string]interface{}
+		expected interface{}
+		err      bool
+	}{
+		"gateway": {
+			obj: map[string]interface{}{
+				"apiVersion": "gateway.networking.k8s.io/v1",
+				"kind":       "Gateway",
+				"metadata":   map[string]interface{}{"name": "public", "namespace": "infra"},
+				"spec": map[string]interface{}{
+					"gatewayClassName": "example",
+					"listeners": []interface{}{
+						map[string]interface{}{"name": "https", "port": int64(443), "protocol": "HTTPS", "hostname": "*.example.com"},
+					},
+				},
+				"status": map[string]interface{}{
+					"addresses": []interface{}{map[string]interface{}{"type": "IPAddress", "value": "192.0.2.10"}},
+				},
+			},
+			expected: &Gateway{
+				TypeMeta:   metaV1.TypeMeta{APIVersion: "gateway.networking.k8s.io/v1", Kind: GatewayKind},
+				ObjectMeta: metaV1.ObjectMeta{Name: "public", Namespace: "infra"},
+				Spec: GatewaySpec{
+					Listeners: []Listener{{Name: "https", Port: 443, Protocol: "HTTPS", Hostname: "*.example.com"}},
+				},
+				Status: GatewayStatus{
+					Addresses: []GatewayAddress{{Type: "IPAddress", Value: "192.0.2.10"}},
+				},
+			},
+		},
+		"httproute": {
+			obj: map[string]interface{}{
+				"apiVersion": "gateway.networking.k8s.io/v1beta1",
+				"kind":       "HTTPRoute",
+				"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+				"spec": map[string]interface{}{
+					"parentRefs": []interface{}{map[string]interface{}{"name": "public", "namespace": "infra"}},
+					"hostnames":  []interface{}{"shop.example.com"},
+					"rules": []interface{}{
+						map[string]interface{}{
+							"matches": []interface{}{
+								map[string]interface{}{"path": map[string]interface{}{"type": "PathPrefix", "value": "/cart"}},
+							},
+							"backendRefs": []interface{}{map[string]interface{}{"name": "web", "port": int64(80)}},
+						},
+					},
+				},
+			},
+			expected: &HTTPRoute{
+				TypeMeta:   metaV1.TypeMeta{APIVersion: "gateway.networking.k8s.io/v1beta1", Kind: HTTPRouteKind},
+				ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "shop"},
+				Spec: HTTPRouteSpec{
+					ParentRefs: []ParentReference{{Name: "public", Namespace: "infra"}},
+					Hostnames:  []string{"shop.example.com"},
+					Rules: []HTTPRouteRule{
+						{Matches: []HTTPRouteMatch{{Path: &HTTPPathMatch{Type: PathMatchPathPrefix, Value: "/cart"}}}},
+					},
+				},
+			},
+		},
+		"unexpected kind": {
+			obj: map[string]interface{}{
+				"apiVersion": "gateway.networking.k8s.io/v1",
+				"kind":       "GRPCRoute",
+				"metadata":   map[string]interface{}{"name": "grpc", "namespace": "shop"},
+			},
+			err: true,
+		},
+		"invalid": {
+			obj: map[string]interface{}{
+				"apiVersion": "gateway.networking.k8s.io/v1",
+				"kind":       "Gateway",
+				"metadata":   map[string]interface{}{"name": "public", "namespace": "infra"},
+				"spec":       "listeners",
+			},
+			err: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			decoded, err := Decode(&unstructured.Unstructured{Object: test.obj})
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, decoded)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package hrw
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// Weight returns the rendezvous (highest random weight) hashing weight of a member for the given key.
+func Weight(key, member string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(member))
+	return mix(h.Sum64())
+}
+
+// mix is the splitmix64 finalizer. FNV alone distributes similar inputs poorly.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Select returns up to n members chosen for key using rendezvous hashing. The selection is stable:
+// the same key always gets the same members, and adding or removing members only affects the keys
+// that had them selected. A non-positive n selects all members.
+//
+// The returned members are ordered by descending weight.
+func Select(key string, members []string, n int) []string {
+	type weighted struct {
+		member string
+		weight uint64
+	}
+
+	candidates := make([]weighted, len(members))
+	for idx, member := range members {
+		candidates[idx] = weighted{
+			member: member,
+			weight: Weight(key, member),
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].weight != candidates[j].weight {
+			return candidates[i].weight > candidates[j].weight
+		}
+		return candidates[i].member < candidates[j].member
+	})
+
+	if n <= 0 || n > len(candidates) {
+		n = len(candidates)
+	}
+
+	selected := make([]string, n)
+	for idx := range selected {
+		selected[idx] = candidates[idx].member
+	}
+	return selected
+}
@@ -0,0 +1,57 @@
+package hrw
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelect(t *testing.T) {
+	members := []string{"a", "b", "c", "d", "e"}
+
+	for name, test := range map[string]struct {
+		n        int
+		expected int
+	}{
+		"all":      {n: 0, expected: 5},
+		"some":     {n: 2, expected: 2},
+		"too many": {n: 10, expected: 5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			selected := Select("key", members, test.n)
+			require.Len(t, selected, test.expected)
+			require.Subset(t, members, selected)
+			require.Equal(t, selected, Select("key", members, test.n))
+		})
+	}
+}
+
+func TestSelectStable(t *testing.T) {
+	members := []string{"a", "b", "c", "d", "e"}
+	changed := 0
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before := Select(key, members, 1)
+		after := Select(key, append(members[:len(members):len(members)], "f"), 1)
+		if before[0] != after[0] {
+			// A key can only move to the new member.
+			require.Equal(t, "f", after[0])
+			changed++
+		}
+	}
+	require.Less(t, changed, 50)
+}
+
+func TestSelectSpread(t *testing.T) {
+	members := []string{"a", "b", "c", "d"}
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		for _, m := range Select(fmt.Sprintf("key-%d", i), members, 1) {
+			counts[m]++
+		}
+	}
+	for _, m := range members {
+		require.Greater(t, counts[m], 150, "member %s", m)
+	}
+}
@@ -7,6 +7,9 @@ import (
 
 	"github.com/adriansr/sm-controller/internal/schema"
 	"github.com/adriansr/sm-controller/internal/watchers"
+	k8s_schema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 )
@@ -17,13 +20,47 @@ const (
 	zeroDuration = time.Duration(0)
 )
 
+// sharedInformerFactory is implemented by the typed and dynamic informer factories.
+type sharedInformerFactory interface {
+	Start(stopCh <-chan struct{})
+}
+
 type Factory struct {
-	inner        informers.SharedInformerFactory
+	inner        sharedInformerFactory
+	forResource  func(k8s_schema.GroupVersionResource) (informers.GenericInformer, error)
+	shutdown     func()
 	resyncPeriod time.Duration
 	errorHandler watchers.ErrorHandler
 }
 
 func NewFactory(client kubernetes.Interface, opts ...FactoryOption) (*Factory, error) {
+	f, err := newFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	inner := informers.NewSharedInformerFactory(client, f.resyncPeriod)
+	f.inner, f.forResource, f.shutdown = inner, inner.ForResource, inner.Shutdown
+	return f, nil
+}
+
+// NewDynamicFactory returns a factory for resources without typed clients, such as CRDs. Its informers
+// publish *unstructured.Unstructured objects.
+func NewDynamicFactory(client dynamic.Interface, opts ...FactoryOption) (*Factory, error) {
+	f, err := newFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	inner := dynamicinformer.NewDynamicSharedInformerFactory(client, f.resyncPeriod)
+	f.inner = inner
+	// The dynamic informers can't be shut down, they stop when the context passed to Start is done.
+	f.shutdown = func() {}
+	f.forResource = func(gvr k8s_schema.GroupVersionResource) (informers.GenericInformer, error) {
+		return inner.ForResource(gvr), nil
+	}
+	return f, nil
+}
+
+func newFactory(opts []FactoryOption) (*Factory, error) {
 	f := &Factory{}
 	for _, opt := range opts {
 		if err := opt(f); err != nil {
@@ -33,12 +70,11 @@ func NewFactory(client kubernetes.Interface, opts ...FactoryOption) (*Factory, e
 	if f.resyncPeriod == zeroDuration {
 		f.resyncPeriod = defaultResyncPeriod
 	}
-	f.inner = informers.NewSharedInformerFactory(client, f.resyncPeriod)
 	return f, nil
 }
 
 func (f *Factory) ForResource(r schema.Resource) (Informer, error) {
-	inner, err := f.inner.ForResource(r.GroupVersionResource())
+	inner, err := f.forResource(r.GroupVersionResource())
 	return &informer{
 		inner:        inner,
 		errorHandler: f.errorHandler,
@@ -50,7 +86,7 @@ func (f *Factory) Start(ctx context.Context) {
 }
 
 func (f *Factory) Stop() {
-	f.inner.Shutdown()
+	f.shutdown()
 }
 
 type FactoryOption func(*Factory) error
@@ -14,7 +14,6 @@ type k8sObject interface {
 	GetNamespace() string
 	GetName() string
 	GetAnnotations() map[string]string
-	Marshal() ([]byte, error)
 }
 
 type Object interface {
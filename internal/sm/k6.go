@@ -0,0 +1,13 @@
+package sm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// scriptHash identifies the content of a k6 script, so that checks can be compared and logged
+// without the script.
+func scriptHash(script []byte) string {
+	sum := sha256.Sum256(script)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,31 @@
+package sm
+
+import (
+	"testing"
+
+	sm_protos "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestK6Script(t *testing.T) {
+	k6Check := func(script string) *Check {
+		return &Check{
+			RawCheck: RawCheck{
+				Job:    "job",
+				Target: "https://example.com/",
+				Settings: sm_protos.CheckSettings{
+					K6: &K6Settings{Script: []byte(script)},
+				},
+			},
+		}
+	}
+
+	check := k6Check("export default function() { login('s3cr3t') }")
+	require.True(t, check.Equals(k6Check("export default function() { login('s3cr3t') }")))
+	require.False(t, check.Equals(k6Check("export default function() { login('0th3r') }")))
+
+	raw := check.Redacted()
+	require.Equal(t, scriptHash(check.Settings.K6.Script), string(raw.Settings.K6.Script))
+	require.NotContains(t, raw.String(), "s3cr3t")
+	require.Contains(t, string(check.Settings.K6.Script), "s3cr3t")
+}
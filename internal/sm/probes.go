@@ -0,0 +1,111 @@
+package sm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adriansr/sm-controller/internal/helpers/hrw"
+)
+
+// Probe selectors that can be used in place of probe names.
+const (
+	AllProbesSelector     = "all"
+	PublicProbesSelector  = "public"
+	PrivateProbesSelector = "private"
+	RegionSelectorPrefix  = "region="
+	LabelSelectorPrefix   = "label:"
+)
+
+// Select returns the probes matching a selector, which is either a probe name, matched
+// case-insensitively, or one of:
+//   - "all", "public" or "private" for all the probes or those of the given kind.
+//   - "region=<region>" for the probes in a region.
+//   - "label:<name>=<value>" for the probes that have a label.
+//
+// Deprecated probes are only returned when selected by name.
+func (s ProbeSet) Select(selector string) ([]*Probe, error) {
+	selector = strings.TrimSpace(selector)
+	var match func(*Probe) bool
+
+	switch lower := strings.ToLower(selector); {
+	case lower == AllProbesSelector:
+		match = func(*Probe) bool { return true }
+
+	case lower == PublicProbesSelector:
+		match = func(p *Probe) bool { return p.Public }
+
+	case lower == PrivateProbesSelector:
+		match = func(p *Probe) bool { return !p.Public }
+
+	case strings.HasPrefix(lower, RegionSelectorPrefix):
+		region := strings.TrimSpace(selector[len(RegionSelectorPrefix):])
+		match = func(p *Probe) bool { return strings.EqualFold(p.Region, region) }
+
+	case strings.HasPrefix(lower, LabelSelectorPrefix):
+		name, value, found := strings.Cut(selector[len(LabelSelectorPrefix):], "=")
+		if !found || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid probe label selector %q", selector)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		match = func(p *Probe) bool {
+			for _, label := range p.Labels {
+				if label.Name == name && label.Value == value {
+					return true
+				}
+			}
+			return false
+		}
+
+	default:
+		probe, found := s[lower]
+		if !found {
+			return nil, fmt.Errorf("probe %s doesn't exist", selector)
+		}
+		return []*Probe{probe}, nil
+	}
+
+	var probes []*Probe
+	for _, probe := range s {
+		if !probe.Deprecated && match(probe) {
+			probes = append(probes, probe)
+		}
+	}
+	if len(probes) == 0 {
+		return nil, fmt.Errorf("probe selector %s doesn't match any probe", selector)
+	}
+	return probes, nil
+}
+
+// ResolveProbeIDs sets the check's probe IDs from its probe names and selectors. When a probe count
+// is set, the probes are chosen from the selected ones using rendezvous hashing on the job name, so
+// that checks keep their probes across syncs and are spread evenly between probes.
+func (c *Check) ResolveProbeIDs(probes ProbeSet) error {
+	seen := make(map[int64]bool)
+	var ids []string
+	for _, selector := range c.Probes {
+		selected, err := probes.Select(selector)
+		if err != nil {
+			return fmt.Errorf("check %s: %w", c.Job, err)
+		}
+		for _, probe := range selected {
+			if !seen[probe.Id] {
+				seen[probe.Id] = true
+				ids = append(ids, strconv.FormatInt(probe.Id, 10))
+			}
+		}
+	}
+	if c.ProbeCount > 0 {
+		ids = hrw.Select(c.Job, ids, c.ProbeCount)
+	}
+	c.RawCheck.Probes = make([]int64, 0, len(ids))
+	for _, id := range ids {
+		value, _ := strconv.ParseInt(id, 10, 64)
+		c.RawCheck.Probes = append(c.RawCheck.Probes, value)
+	}
+	sort.Slice(c.RawCheck.Probes, func(i, j int) bool {
+		return c.RawCheck.Probes[i] < c.RawCheck.Probes[j]
+	})
+	return nil
+}
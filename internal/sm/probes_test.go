@@ -0,0 +1,133 @@
+package sm
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveProbeIDs(t *testing.T) {
+	probes := ProbeSet{}
+	for _, probe := range []*Probe{
+		{Id: 1, Name: "Paris", Region: "EMEA", Public: true},
+		{Id: 2, Name: "Frankfurt", Region: "EMEA", Public: true, Labels: []Label{{Name: "tier", Value: "gold"}}},
+		{Id: 3, Name: "NewYork", Region: "AMER", Public: true},
+		{Id: 4, Name: "OnPrem", Region: "EMEA", Labels: []Label{{Name: "tier", Value: "gold"}}},
+		{Id: 5, Name: "Legacy", Region: "AMER", Public: true, Deprecated: true},
+	} {
+		probes[strings.ToLower(probe.Name)] = probe
+	}
+
+	for name, test := range map[string]struct {
+		selectors []string
+		expected  []int64
+		err       bool
+	}{
+		"names": {
+			selectors: []string{"paris", "NEWYORK"},
+			expected:  []int64{1, 3},
+		},
+		"deprecated by name": {
+			selectors: []string{"Legacy"},
+			expected:  []int64{5},
+		},
+		"all": {
+			selectors: []string{"all"},
+			expected:  []int64{1, 2, 3, 4},
+		},
+		"public": {
+			selectors: []string{"Public"},
+			expected:  []int64{1, 2, 3},
+		},
+		"private": {
+			selectors: []string{"private"},
+			expected:  []int64{4},
+		},
+		"region": {
+			selectors: []string{"region=emea"},
+			expected:  []int64{1, 2, 4},
+		},
+		"label": {
+			selectors: []string{"label:tier=gold"},
+			expected:  []int64{2, 4},
+		},
+		"duplicates": {
+			selectors: []string{"region=EMEA", "public", "Paris"},
+			expected:  []int64{1, 2, 3, 4},
+		},
+		"unknown probe": {
+			selectors: []string{"Tokyo"},
+			err:       true,
+		},
+		"no matches": {
+			selectors: []string{"region=APAC"},
+			err:       true,
+		},
+		"invalid label selector": {
+			selectors: []string{"label:tier"},
+			err:       true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			check := &Check{
+				RawCheck: RawCheck{Job: "job"},
+				Probes:   test.selectors,
+			}
+			err := check.ResolveProbeIDs(probes)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, check.RawCheck.Probes)
+		})
+	}
+}
+
+func TestResolveProbeCount(t *testing.T) {
+	probes := ProbeSet{}
+	for id := int64(1); id <= 20; id++ {
+		name := "probe" + strconv.FormatInt(id, 10)
+		probes[name] = &Probe{Id: id, Name: name, Public: id <= 10}
+	}
+
+	resolve := func(job string, count int, selectors ...string) []int64 {
+		check := &Check{
+			RawCheck:   RawCheck{Job: job},
+			Probes:     selectors,
+			ProbeCount: count,
+		}
+		require.NoError(t, check.ResolveProbeIDs(probes))
+		return check.RawCheck.Probes
+	}
+
+	t.Run("count", func(t *testing.T) {
+		require.Len(t, resolve("job", 3, "all"), 3)
+		require.Len(t, resolve("job", 30, "all"), 20)
+	})
+
+	t.Run("stable", func(t *testing.T) {
+		require.Equal(t, resolve("job", 3, "all"), resolve("job", 3, "all"))
+	})
+
+	t.Run("subset of selected", func(t *testing.T) {
+		for _, id := range resolve("job", 5, "public") {
+			require.LessOrEqual(t, id, int64(10))
+		}
+	})
+
+	t.Run("spread", func(t *testing.T) {
+		counts := make(map[int64]int)
+		for idx := 0; idx < 1000; idx++ {
+			for _, id := range resolve("job"+strconv.Itoa(idx), 2, "all") {
+				counts[id]++
+			}
+		}
+		require.Len(t, counts, 20)
+		for id, count := range counts {
+			require.InDelta(t, 100, count, 40, "probe %d", id)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package sm
+
+const redacted = "<redacted>"
+
+// Redacted returns a copy of the check with the secret values replaced, so that it can be logged.
+func (c *Check) Redacted() RawCheck {
+	raw := c.RawCheck
+	if http := raw.Settings.Http; http != nil {
+		settings := *http
+		if settings.BasicAuth != nil {
+			auth := *settings.BasicAuth
+			auth.Password = redacted
+			settings.BasicAuth = &auth
+		}
+		if settings.BearerToken != "" {
+			settings.BearerToken = redacted
+		}
+		settings.TlsConfig = redactTLS(settings.TlsConfig)
+		raw.Settings.Http = &settings
+	}
+	if tcp := raw.Settings.Tcp; tcp != nil {
+		settings := *tcp
+		settings.TlsConfig = redactTLS(settings.TlsConfig)
+		raw.Settings.Tcp = &settings
+	}
+	if k6 := raw.Settings.K6; k6 != nil {
+		// Scripts can hold credentials and are too long to log, only their hash is logged.
+		raw.Settings.K6 = &K6Settings{Script: []byte(scriptHash(k6.Script))}
+	}
+	return raw
+}
+
+func redactTLS(config *TLSConfig) *TLSConfig {
+	if config == nil || len(config.ClientKey) == 0 {
+		return config
+	}
+	result := *config
+	result.ClientKey = []byte(redacted)
+	return &result
+}
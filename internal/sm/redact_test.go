@@ -0,0 +1,36 @@
+package sm
+
+import (
+	"testing"
+
+	sm_protos "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedacted(t *testing.T) {
+	check := &Check{
+		RawCheck: RawCheck{
+			Job: "job",
+			Settings: sm_protos.CheckSettings{
+				Http: &HttpSettings{
+					BasicAuth:   &sm_protos.BasicAuth{Username: "admin", Password: "s3cr3t"},
+					BearerToken: "t0k3n",
+					TlsConfig:   &TLSConfig{ClientCert: []byte("cert"), ClientKey: []byte("k3y")},
+				},
+			},
+		},
+	}
+
+	raw := check.Redacted()
+	require.Equal(t, "admin", raw.Settings.Http.BasicAuth.Username)
+	require.Equal(t, redacted, raw.Settings.Http.BasicAuth.Password)
+	require.Equal(t, redacted, raw.Settings.Http.BearerToken)
+	require.Equal(t, []byte("cert"), raw.Settings.Http.TlsConfig.ClientCert)
+	require.Equal(t, []byte(redacted), raw.Settings.Http.TlsConfig.ClientKey)
+	require.NotContains(t, raw.String(), "s3cr3t")
+
+	// The original check is unchanged.
+	require.Equal(t, "s3cr3t", check.Settings.Http.BasicAuth.Password)
+	require.Equal(t, "t0k3n", check.Settings.Http.BearerToken)
+	require.Equal(t, []byte("k3y"), check.Settings.Http.TlsConfig.ClientKey)
+}
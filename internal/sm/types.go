@@ -4,18 +4,34 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
-	"strings"
 
 	sm_protos "github.com/grafana/synthetic-monitoring-agent/pkg/pb/synthetic_monitoring"
 )
 
 type RawCheck = sm_protos.Check
+type CheckSettings = sm_protos.CheckSettings
 type TcpSettings = sm_protos.TcpSettings
+type HttpSettings = sm_protos.HttpSettings
+type PingSettings = sm_protos.PingSettings
+type DnsSettings = sm_protos.DnsSettings
+type DnsRecordType = sm_protos.DnsRecordType
+type DnsProtocol = sm_protos.DnsProtocol
+type DNSRRValidator = sm_protos.DNSRRValidator
+type TracerouteSettings = sm_protos.TracerouteSettings
+type K6Settings = sm_protos.K6Settings
+type TLSConfig = sm_protos.TLSConfig
 type Probe = sm_protos.Probe
 type Label = sm_protos.Label
+type IpVersion = sm_protos.IpVersion
 
 const (
-	IpVersion_V4 = sm_protos.IpVersion_V4
+	IpVersion_Any = sm_protos.IpVersion_Any
+	IpVersion_V4  = sm_protos.IpVersion_V4
+	IpVersion_V6  = sm_protos.IpVersion_V6
+
+	MaxCheckLabels     = sm_protos.MaxCheckLabels
+	MaxPingPackets     = sm_protos.MaxPingPackets
+	MaxPingPayloadSize = 65499
 
 	// TODO: Move somewhere else
 	ManagedLabel = "managed_by"
@@ -26,6 +42,10 @@ type Check struct {
 	RawCheck
 
 	Probes []string // Override probes as list of string
+	// ProbeCount limits the number of probes selected by Probes when greater than zero.
+	ProbeCount int
+	// Owner is the namespace and name of the SyntheticCheck that defines the check, if any.
+	Owner string
 }
 
 type CheckSet map[string]*Check
@@ -69,6 +89,14 @@ func normalize(c RawCheck) RawCheck {
 	sort.SliceStable(c.Probes, func(i, j int) bool {
 		return c.Probes[i] < c.Probes[j]
 	})
+	if k6 := c.Settings.K6; k6 != nil {
+		// Scripts are compared by their hash.
+		c.Settings.K6 = &K6Settings{Script: []byte(scriptHash(k6.Script))}
+	}
+	c.Labels = append([]Label(nil), c.Labels...)
+	sort.SliceStable(c.Labels, func(i, j int) bool {
+		return c.Labels[i].Name < c.Labels[j].Name
+	})
 	return c
 }
 
@@ -89,15 +117,3 @@ func (c *Check) MarkManaged() {
 		})
 	}
 }
-
-func (c *Check) ResolveProbeIDs(probes ProbeSet) error {
-	c.RawCheck.Probes = make([]int64, 0, len(c.Probes))
-	for _, name := range c.Probes {
-		probe, found := probes[strings.ToLower(name)]
-		if !found {
-			return fmt.Errorf("check %s references probe %s that doesn't exist", c.Job, name)
-		}
-		c.RawCheck.Probes = append(c.RawCheck.Probes, probe.Id)
-	}
-	return nil
-}
@@ -0,0 +1,47 @@
+package state
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/adriansr/sm-controller/internal/builder"
+)
+
+var skippedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "sm_controller",
+	Subsystem: "builder",
+	Name:      "skipped",
+	Help:      "Number of objects or ports that didn't get a check in the last build, by reason.",
+}, []string{
+	"reason",
+})
+
+var configValidGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "sm_controller",
+	Subsystem: "config",
+	Name:      "last_reload_successful",
+	Help:      "Whether the last config ConfigMap received was valid.",
+})
+
+func RegisterMetrics(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{skippedGauge, configValidGauge} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func updateSkippedMetrics(counts map[builder.SkipReason]int) {
+	skippedGauge.Reset()
+	for reason, count := range counts {
+		skippedGauge.WithLabelValues(string(reason)).Set(float64(count))
+	}
+}
+
+func updateConfigMetrics(valid bool) {
+	value := 0.0
+	if valid {
+		value = 1
+	}
+	configValidGauge.Set(value)
+}
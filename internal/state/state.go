@@ -2,6 +2,7 @@ package state
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,6 +10,8 @@ import (
 	"time"
 
 	"github.com/adriansr/sm-controller/internal/builder"
+	"github.com/adriansr/sm-controller/internal/crd"
+	"github.com/adriansr/sm-controller/internal/gateway"
 	"github.com/adriansr/sm-controller/internal/helpers/timer"
 	"github.com/adriansr/sm-controller/internal/schema"
 	"github.com/adriansr/sm-controller/internal/sm"
@@ -16,6 +19,8 @@ import (
 	"github.com/rs/zerolog"
 	coreV1 "k8s.io/api/core/v1"
 	networkingV1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
 
 	client "github.com/grafana/synthetic-monitoring-api-go-client"
 )
@@ -23,10 +28,11 @@ import (
 type Version uint32
 
 type ClusterState struct {
-	Services  []*coreV1.Service
-	Ingresses []*networkingV1.Ingress
-	Version   Version
-	Force     bool
+	builder.Objects
+	// Config is the controller's config ConfigMap, if any.
+	Config  *coreV1.ConfigMap
+	Version Version
+	Force   bool
 }
 
 type Publisher interface {
@@ -37,30 +43,108 @@ type State struct {
 	C         <-chan watchers.Event
 	Logger    zerolog.Logger
 	Publisher Publisher
+	// ConfigMap is the controller's config ConfigMap. Other ConfigMaps are published as objects
+	// that checks can reference.
+	ConfigMap types.NamespacedName
 
 	internalState map[string]schema.Object
 	lastPublished Version
+	// refs are the Secrets and ConfigMaps referenced by the objects in the state, or nil when they
+	// must be computed again.
+	refs *builder.References
 }
 
 func (s *State) publish(forced bool) {
 	s.lastPublished++
-	update := ClusterState{
-		Version: s.lastPublished,
-		Force:   forced,
-	}
+	update := s.clusterState()
+	update.Version = s.lastPublished
+	update.Force = forced
+	s.Publisher.Publish(update)
+}
 
+// clusterState returns the objects in the state.
+func (s *State) clusterState() (update ClusterState) {
 	for _, obj := range s.internalState {
 		switch v := obj.Inner().(type) {
 		case *coreV1.Service:
 			update.Services = append(update.Services, v)
 		case *networkingV1.Ingress:
 			update.Ingresses = append(update.Ingresses, v)
+		case *coreV1.Node:
+			update.Nodes = append(update.Nodes, v)
+		case *coreV1.Namespace:
+			update.Namespaces = append(update.Namespaces, v)
+		case *coreV1.ConfigMap:
+			if v.Namespace == s.ConfigMap.Namespace && v.Name == s.ConfigMap.Name {
+				update.Config = v
+			} else {
+				update.ConfigMaps = append(update.ConfigMaps, v)
+			}
+		case *coreV1.Secret:
+			update.Secrets = append(update.Secrets, v)
+		case *gateway.Gateway:
+			update.Gateways = append(update.Gateways, v)
+		case *gateway.HTTPRoute:
+			update.HTTPRoutes = append(update.HTTPRoutes, v)
+		case *crd.SyntheticCheck:
+			update.SyntheticChecks = append(update.SyntheticChecks, v)
 		default:
 			panic(fmt.Errorf("unexpected type: %T", v))
 		}
 	}
+	return update
+}
 
-	s.Publisher.Publish(update)
+// triggersSync returns whether a change to an object can change the checks. Secrets and ConfigMaps
+// only do when they're referenced by the objects in the state.
+func (s *State) triggersSync(obj schema.Object) bool {
+	switch v := obj.Inner().(type) {
+	case *coreV1.Secret:
+		return s.references().Secret(v.Namespace, v.Name)
+	case *coreV1.ConfigMap:
+		if v.Namespace == s.ConfigMap.Namespace && v.Name == s.ConfigMap.Name {
+			s.refs = nil
+			return true
+		}
+		return s.references().ConfigMap(v.Namespace, v.Name)
+	default:
+		s.refs = nil
+		return true
+	}
+}
+
+func (s *State) references() *builder.References {
+	if s.refs == nil {
+		cs := s.clusterState()
+		var config map[string]string
+		if cs.Config != nil {
+			config = cs.Config.Data
+		}
+		s.refs = builder.NewReferences(cs.Objects, config)
+	}
+	return s.refs
+}
+
+// decode converts the unstructured objects from dynamic informers to their types.
+func decode(obj schema.Object) (schema.Object, error) {
+	u, ok := obj.Inner().(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+	var decoded interface{}
+	var err error
+	switch group := u.GroupVersionKind().Group; group {
+	case gateway.Group:
+		decoded, err = gateway.Decode(u)
+	case crd.Group:
+		decoded, err = crd.Decode(u)
+	default:
+		err = fmt.Errorf("unexpected group %s", group)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schema.ObjectFrom(decoded)
 }
 
 func (s *State) Run(ctx context.Context) error {
@@ -105,12 +189,24 @@ func (s *State) Run(ctx context.Context) error {
 		case ev := <-s.C:
 			key := ev.Obj.ID()
 			s.Logger.Info().Str("action", ev.Action.String()).Str("id", key).Msg("received event")
+			obj := ev.Obj
 			switch ev.Action {
 			case watchers.Add, watchers.Update:
-				s.internalState[key] = ev.Obj
+				decoded, err := decode(ev.Obj)
+				if err != nil {
+					s.Logger.Warn().Err(err).Str("id", key).Msg("ignoring object")
+					delete(s.internalState, key)
+					break
+				}
+				obj = decoded
+				s.internalState[key] = obj
 			case watchers.Delete:
 				delete(s.internalState, key)
 			}
+			if !s.triggersSync(obj) {
+				s.Logger.Debug().Str("id", key).Msg("object not referenced by checks")
+				continue
+			}
 
 			if !deadlines.IsSet(maxSync) {
 				deadlines.Set(maxSync, time.Now().Add(maxSyncTimeout))
@@ -138,6 +234,19 @@ type Consolidator struct {
 
 	ApiServer string
 	ApiToken  string
+
+	// BuilderOptions are the options used to build checks, unless overridden by the config ConfigMap.
+	BuilderOptions builder.Options
+
+	// configVersion and configOptions are the resource version of the last valid config ConfigMap
+	// and the options built from it.
+	configVersion string
+	configOptions builder.Options
+
+	// StatusWriter records the sync result in the SyntheticChecks, if set.
+	StatusWriter StatusWriter
+	// statuses are the last known statuses of the SyntheticChecks, by UID.
+	statuses map[types.UID]crd.SyntheticCheckStatus
 }
 
 func (p *Consolidator) Publish(cs ClusterState) {
@@ -186,54 +295,93 @@ func (p *Consolidator) syncState(cs ClusterState) error {
 	logger.Info().
 		Int("num_services", len(cs.Services)).
 		Int("num_ingresses", len(cs.Ingresses)).
+		Int("num_nodes", len(cs.Nodes)).
 		Msg("Starting sync")
 
-	bld := builder.NewBuilder(builder.NewOptions())
-	checks, warns := bld.Build(cs.Services, cs.Ingresses)
+	bld := builder.NewBuilder(p.builderOptions(cs.Config, logger))
+	checks, warns := bld.Build(cs.Objects)
 
 	logger.Debug().Int("num_checks", len(checks)).Int("warnings", len(warns)).Msg("check build finished")
 
-	if len(warns) > 0 {
-		logger.Warn().Int("count", len(warns)).Msg("check build resulted in warnings")
-		for idx, w := range warns {
-			var ids []string
-			for _, obj := range w.Objs {
-				ids = append(ids, obj.ID())
-			}
-			logger.Warn().Int("warning", idx).Interface("resources", ids).Msg(w.Cause.Error())
+	skipped := make(map[builder.SkipReason]int)
+	numWarns := 0
+	for idx, w := range warns {
+		var ids []string
+		for _, obj := range w.Objs {
+			ids = append(ids, obj.ID())
 		}
+		var skip *builder.Skipped
+		if errors.As(w.Cause, &skip) {
+			skipped[skip.Reason]++
+			logger.Info().
+				Str("reason", string(skip.Reason)).
+				Str("port", skip.Port).
+				Interface("resources", ids).
+				Msg(skip.Error())
+			continue
+		}
+		logger.Warn().Int("warning", idx).Interface("resources", ids).Msg(w.Cause.Error())
+		numWarns++
+	}
+	updateSkippedMetrics(skipped)
+	if numWarns > 0 {
+		logger.Warn().Int("count", numWarns).Msg("check build resulted in warnings")
 	}
 	for idx, check := range checks {
-		p.Logger.Debug().Int("number", idx).Msgf("%+v", check)
+		p.Logger.Debug().Int("number", idx).Interface("check", check.Redacted()).Msg("built check")
+	}
+
+	result, err := p.reconcile(logger, cs.Force, checks)
+	p.updateSyntheticChecks(logger, cs.SyntheticChecks, checks, warns, result, err)
+	return err
+}
+
+// syncResult holds the check IDs in the API after a sync and the checks that couldn't be synced,
+// by job name.
+type syncResult struct {
+	ids    map[string]int64
+	failed map[string]error
+}
+
+// reconcile creates, updates and deletes the checks in the API so that they match the built checks.
+func (p *Consolidator) reconcile(logger zerolog.Logger, force bool, checks []*sm.Check) (result syncResult, err error) {
+	result = syncResult{
+		ids:    make(map[string]int64),
+		failed: make(map[string]error),
 	}
 
 	api, err := p.getAPIObjects()
 	if err != nil {
-		return fmt.Errorf("fetching state from synthetic-monitoring API: %w", err)
+		return result, fmt.Errorf("fetching state from synthetic-monitoring API: %w", err)
 	}
 	for key, probe := range api.probes {
 		logger.Debug().Msgf("API: got probe[%s] = %d", key, probe.Id)
 	}
 	for key, check := range api.checks {
-		logger.Debug().Msgf("API: got check[%s] = %+v", key, check.RawCheck)
+		logger.Debug().Str("job", key).Interface("check", check.Redacted()).Msg("API: got check")
 	}
 
 	for _, newCheck := range checks {
 		if err := newCheck.ResolveProbeIDs(api.probes); err != nil {
 			// TODO: Only err current check!
-			return err
+			result.failed[newCheck.Job] = err
+			return result, err
 		}
+		newCheck.MarkManaged()
 	}
 
 	set, err := sm.NewCheckSet(checks)
 	if err != nil {
 		// Should only happen if we create repeated job names
-		return fmt.Errorf("error in generated check set: %w", err)
+		return result, fmt.Errorf("error in generated check set: %w", err)
 	}
 
-	if !cs.Force && api.checks.Equals(set) {
+	if !force && api.checks.Equals(set) {
 		logger.Info().Msg("Skipping sync: no changes")
-		return nil
+		for jobName, known := range api.checks {
+			result.ids[jobName] = known.Id
+		}
+		return result, nil
 	}
 
 	var add, update, del []*sm.Check
@@ -244,14 +392,13 @@ func (p *Consolidator) syncState(cs ClusterState) error {
 			continue
 		}
 		if check.Equals(known) {
+			result.ids[jobName] = known.Id
 			continue
 		}
 		check.Id = known.Id
 		check.TenantId = known.TenantId
 		check.Created = known.Created
 		check.Modified = 0 // known.Modified
-		check.Labels = known.Labels
-		check.MarkManaged()
 		update = append(update, check)
 	}
 
@@ -276,42 +423,78 @@ func (p *Consolidator) syncState(cs ClusterState) error {
 		if _, err := withTimeout(context.TODO(), p.RequestTimeout, func(ctx context.Context) (int64, error) {
 			return check.Id, cli.DeleteCheck(ctx, check.Id)
 		}); err != nil {
-			return fmt.Errorf("deleting check %s[id=%d]: %w", check.Job, check.Id, err)
+			return result, fmt.Errorf("deleting check %s[id=%d]: %w", check.Job, check.Id, err)
 		}
 	}
 
 	for _, check := range update {
-		logger.Debug().Int64("id", check.Id).Str("job", check.Job).Interface("check", check.RawCheck).Msg("Updating check")
+		logger.Debug().Int64("id", check.Id).Str("job", check.Job).Interface("check", check.Redacted()).Msg("Updating check")
 
 		if _, err := withTimeout(context.TODO(), p.RequestTimeout, func(ctx context.Context) (int64, error) {
-			result, err := cli.UpdateCheck(ctx, check.RawCheck)
+			updated, err := cli.UpdateCheck(ctx, check.RawCheck)
 			if err != nil {
 				return 0, err
 			}
-			return result.Id, nil
+			return updated.Id, nil
 		}); err != nil {
-			return fmt.Errorf("deleting check %s[id=%d]: %w", check.Job, check.Id, err)
+			result.failed[check.Job] = err
+			return result, fmt.Errorf("deleting check %s[id=%d]: %w", check.Job, check.Id, err)
 		}
+		result.ids[check.Job] = check.Id
 	}
 
 	for _, check := range add {
-		check.MarkManaged() // TODO: Here?
-		logger.Debug().Str("job", check.Job).Interface("check", check.RawCheck).Msg("Creating check")
+		logger.Debug().Str("job", check.Job).Interface("check", check.Redacted()).Msg("Creating check")
 
-		if _, err := withTimeout(context.TODO(), p.RequestTimeout, func(ctx context.Context) (int64, error) {
-			result, err := cli.AddCheck(ctx, check.RawCheck)
+		id, err := withTimeout(context.TODO(), p.RequestTimeout, func(ctx context.Context) (int64, error) {
+			created, err := cli.AddCheck(ctx, check.RawCheck)
 			if err != nil {
 				return 0, err
 			}
-			return result.Id, nil
-		}); err != nil {
-			return fmt.Errorf("deleting check %s[id=%d]: %w", check.Job, check.Id, err)
+			return created.Id, nil
+		})
+		if err != nil {
+			result.failed[check.Job] = err
+			return result, fmt.Errorf("deleting check %s[id=%d]: %w", check.Job, check.Id, err)
 		}
+		result.ids[check.Job] = id
 	}
 
 	logger.Debug().Msg("Done")
 
-	return nil
+	return result, nil
+}
+
+// builderOptions returns the options to build checks with. An invalid config is reported and the
+// previous valid config is kept.
+func (p *Consolidator) builderOptions(cm *coreV1.ConfigMap, logger zerolog.Logger) builder.Options {
+	if cm == nil {
+		if p.configVersion != "" {
+			logger.Info().Msg("config removed, using the default options")
+			p.configVersion = ""
+		}
+		updateConfigMetrics(true)
+		return p.BuilderOptions
+	}
+	if cm.ResourceVersion == p.configVersion {
+		return p.configOptions
+	}
+
+	opts, err := builder.ParseConfig(p.BuilderOptions, cm.Data)
+	if err != nil {
+		logger.Error().Err(err).Str("version", cm.ResourceVersion).Msg("invalid config, keeping the previous one")
+		updateConfigMetrics(false)
+		if p.configVersion == "" {
+			return p.BuilderOptions
+		}
+		return p.configOptions
+	}
+
+	logger.Info().Str("version", cm.ResourceVersion).Msg("config loaded")
+	updateConfigMetrics(true)
+	p.configVersion = cm.ResourceVersion
+	p.configOptions = opts
+	return opts
 }
 
 type apiState struct {
@@ -0,0 +1,111 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/adriansr/sm-controller/internal/builder"
+	"github.com/adriansr/sm-controller/internal/crd"
+	"github.com/adriansr/sm-controller/internal/sm"
+)
+
+// StatusWriter updates the status of SyntheticChecks.
+type StatusWriter interface {
+	UpdateStatus(ctx context.Context, sc *crd.SyntheticCheck, status crd.SyntheticCheckStatus) error
+}
+
+// updateSyntheticChecks records the result of a sync in the status of the SyntheticChecks. syncErr is
+// the error that stopped the sync, if any.
+func (p *Consolidator) updateSyntheticChecks(logger zerolog.Logger, scs []*crd.SyntheticCheck, checks []*sm.Check,
+	warns []builder.Warning, result syncResult, syncErr error,
+) {
+	if p.StatusWriter == nil || len(scs) == 0 {
+		return
+	}
+
+	jobs := make(map[string]string)
+	for _, check := range checks {
+		if check.Owner != "" {
+			jobs[check.Owner] = check.Job
+		}
+	}
+	buildErrs := make(map[string][]string)
+	for _, w := range warns {
+		var skip *builder.Skipped
+		if errors.As(w.Cause, &skip) {
+			continue
+		}
+		for _, obj := range w.Objs {
+			if sc, ok := obj.Inner().(*crd.SyntheticCheck); ok {
+				key := sc.Namespace + "/" + sc.Name
+				buildErrs[key] = append(buildErrs[key], w.Cause.Error())
+			}
+		}
+	}
+
+	// Status changes aren't published, so the status in the cluster state is only current until the
+	// controller first writes it.
+	statuses := make(map[types.UID]crd.SyntheticCheckStatus, len(scs))
+	now := metaV1.NewTime(time.Now())
+	for _, sc := range scs {
+		key := sc.Namespace + "/" + sc.Name
+		current, found := p.statuses[sc.UID]
+		if !found {
+			current = sc.Status
+		}
+		status := syntheticCheckStatus(sc, current, jobs[key], buildErrs[key], result, syncErr, now)
+		statuses[sc.UID] = current
+		if status == current {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), p.RequestTimeout)
+		if err := p.StatusWriter.UpdateStatus(ctx, sc, status); err != nil {
+			logger.Warn().Err(err).Msg("updating synthetic check status")
+		} else {
+			statuses[sc.UID] = status
+		}
+		cancel()
+	}
+	p.statuses = statuses
+}
+
+// syntheticCheckStatus returns the status of a SyntheticCheck after a sync, given its current status.
+// The sync time is only updated when its check gets in sync, so that unchanged checks don't need a
+// status update on every sync.
+func syntheticCheckStatus(sc *crd.SyntheticCheck, current crd.SyntheticCheckStatus, job string, buildErrs []string,
+	result syncResult, syncErr error, now metaV1.Time,
+) crd.SyntheticCheckStatus {
+	status := crd.SyntheticCheckStatus{
+		ObservedGeneration: sc.Generation,
+		Job:                job,
+		LastSyncTime:       current.LastSyncTime,
+		LastError:          strings.Join(buildErrs, "; "),
+	}
+	if job == "" {
+		// Disabled or invalid, there's no check in the API.
+		return status
+	}
+
+	id, synced := result.ids[job]
+	switch err := result.failed[job]; {
+	case err != nil:
+		status.LastError = err.Error()
+		status.CheckID = current.CheckID
+	case synced:
+		status.CheckID = id
+		if status.LastSyncTime == nil || current.CheckID != id || current.LastError != "" ||
+			current.ObservedGeneration != sc.Generation {
+			status.LastSyncTime = &now
+		}
+	case syncErr != nil:
+		status.LastError = syncErr.Error()
+		status.CheckID = current.CheckID
+	}
+	return status
+}